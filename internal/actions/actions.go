@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
@@ -25,6 +26,22 @@ type Actions struct {
 	CurrentJobFile string
 	Probe          [][]*point.Point
 	ProbeSpline    *interp2d.Spline
+	AutoLevelCfg   autolevel.Config
+	ToolChangeCfg  ToolChangeConfig
+	ErrorPolicy    ErrorPolicy
+
+	// file line number of each line of the current job
+	currentLines []int
+
+	// origin of the lines of the running job in the g-code file
+	running *jobOrigin
+
+	// gcode state of grbl when the running job was started
+	runningState gcode.State
+	progress     jobProgress
+	toolChange   toolChange
+	linePause    linePause
+	alignment    *alignment
 }
 
 func (a *Actions) Home(ctx context.Context) error {
@@ -40,7 +57,79 @@ func (a *Actions) Reset(ctx context.Context) error {
 		return ErrGrblNotSet
 	}
 
-	return a.Grbl.SendCommands(ctx, string([]byte{0x18}))
+	return a.Grbl.SoftReset()
+}
+
+func (a *Actions) FeedHold(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.FeedHold()
+}
+
+func (a *Actions) CycleStart(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.CycleStart()
+}
+
+func (a *Actions) SafetyDoor(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.SafetyDoor()
+}
+
+func (a *Actions) JogCancel(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.JogCancel()
+}
+
+func (a *Actions) FeedOverride(ctx context.Context, delta int) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.FeedOverride(delta)
+}
+
+func (a *Actions) FeedOverrideReset(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.FeedOverrideReset()
+}
+
+func (a *Actions) RapidOverride(ctx context.Context, percent int) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.RapidOverride(percent)
+}
+
+func (a *Actions) SpindleOverride(ctx context.Context, delta int) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.SpindleOverride(delta)
+}
+
+func (a *Actions) SpindleOverrideReset(ctx context.Context) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.SpindleOverrideReset()
 }
 
 func (a *Actions) Jog(ctx context.Context, x float64, y float64, z float64) error {
@@ -79,7 +168,7 @@ func (a *Actions) SetZeroXY(ctx context.Context) error {
 		return ErrGrblNotSet
 	}
 
-	return a.Grbl.SendGCodeInline(ctx, "G10 L20 P1 X0 Y0")
+	return a.SetZero(ctx, 0, "XY")
 }
 
 func (a *Actions) ProbeZ(ctx context.Context) error {
@@ -87,6 +176,12 @@ func (a *Actions) ProbeZ(ctx context.Context) error {
 		return ErrGrblNotSet
 	}
 
+	return a.probeZ(ctx, 0)
+}
+
+// probeZ probes the surface below the current position, and sets its work
+// Z position to z.
+func (a *Actions) probeZ(ctx context.Context, z float64) error {
 	if err := a.Grbl.SendGCodeInline(ctx, `
 G91
 G38.2 Z-100 F50
@@ -97,18 +192,48 @@ G04 P0.001`); err != nil {
 		return err
 	}
 
-	if err := a.Grbl.SendCommands(ctx, "?"); err != nil {
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
 		return err
 	}
 
-	if a.Grbl.LastProbe == nil || a.Grbl.MPos == nil {
+	a.Grbl.RLock()
+	probe, mpos := a.Grbl.LastProbe, a.Grbl.MPos
+	a.Grbl.RUnlock()
+
+	if probe == nil || mpos == nil {
 		return errors.New("actions: probe-z: probe failed")
 	}
 
 	return a.Grbl.SendGCodeInline(ctx, fmt.Sprintf(`
-G10 L20 P1 Z%.3f
+G10 L20 P0 Z%.3f
 G01 Z2 F100
-G04 P0.001`, a.Grbl.MPos.Z-a.Grbl.LastProbe.Z))
+G04 P0.001`, mpos.Z-probe.Z+z))
+}
+
+func (a *Actions) SetStreaming(enabled bool) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	a.Grbl.Streaming = enabled
+	if enabled {
+		log.Print("streaming: character-counting protocol enabled")
+	} else {
+		log.Print("streaming: send-response protocol enabled")
+	}
+	return nil
+}
+
+// SetStatusInterval changes the interval between the status reports
+// requested from grbl.
+func (a *Actions) SetStatusInterval(d time.Duration) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	a.Grbl.SetStatusInterval(d)
+	log.Print("status: requesting reports every ", a.Grbl.StatusInterval())
+	return nil
 }
 
 func (a *Actions) LoadGCode(ctx context.Context, file string) error {
@@ -116,17 +241,19 @@ func (a *Actions) LoadGCode(ctx context.Context, file string) error {
 		return ErrGrblNotSet
 	}
 
-	j, err := gcode.NewJobFromFile(file)
+	j, lines, err := readJobFile(file)
 	if err != nil {
 		return err
 	}
 	a.CurrentJob = j
 	a.CurrentJobFile = file
+	a.currentLines = lines
+	a.alignment = nil
 
 	return nil
 }
 
-func (a *Actions) autoLevelProbe(ctx context.Context, x float64, y float64) error {
+func (a *Actions) autoLevelProbe(ctx context.Context, x float64, y float64) (*point.Point, error) {
 	if err := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf(`
 G90
 G01 Z2 F10000
@@ -138,14 +265,17 @@ G38.2 Z-2 F10
 G90
 G01 Z2 F100
 G04 P0.001`, x, y)); err != nil {
-		return err
+		return nil, err
 	}
 
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
 	if a.Grbl.LastProbe == nil || a.Grbl.MPos == nil {
-		return errors.New("actions: autolevel: probe failed")
+		return nil, errors.New("actions: autolevel: probe failed")
 	}
 
-	return nil
+	return a.Grbl.LastProbe.Copy(), nil
 }
 
 func (a *Actions) autoLevelLoadProbe(pts [][]*point.Point, wco *point.Point) error {
@@ -161,98 +291,106 @@ func (a *Actions) autoLevelLoadProbe(pts [][]*point.Point, wco *point.Point) err
 	if err != nil {
 		return err
 	}
-	a.ProbeSpline.Close()
+	// the running job needs its spline to be resumed
+	if a.running == nil || a.running.spline != a.ProbeSpline {
+		a.ProbeSpline.Close()
+	}
 	a.ProbeSpline = sp
 
 	return nil
 }
 
-func (a *Actions) AutoLevel(ctx context.Context) error {
-	if a == nil || a.Grbl == nil {
-		return ErrGrblNotSet
+// AutoLevelGrid returns the grid of probe points for the current job.
+func (a *Actions) AutoLevelGrid(cfg autolevel.GridConfig) (*autolevel.Grid, error) {
+	if a == nil {
+		return nil, ErrGrblNotSet
 	}
 
 	if a.CurrentJob == nil {
-		return errors.New("actions: autolevel: no g-code loaded")
+		return nil, errors.New("actions: autolevel: no g-code loaded")
 	}
 
-	minx, miny, maxx, maxy, err := a.CurrentJob.GetBoundingBox()
+	minx, miny, maxx, maxy, err := a.BoundingBox()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	minx -= 0.2
-	miny -= 0.2
-	maxx += 0.2
-	maxy += 0.2
 
-	distx := maxx - minx
-	numx := int(distx / 10)
+	return autolevel.NewGrid(minx, miny, maxx, maxy, cfg)
+}
 
-	disty := maxy - miny
-	numy := int(disty / 10)
+// BoundingBox returns the XY area covered by the current job, starting
+// from the current gcode state and position of grbl.
+func (a *Actions) BoundingBox() (float64, float64, float64, float64, error) {
+	if a == nil {
+		return 0, 0, 0, 0, ErrGrblNotSet
+	}
 
-	xgap := distx / (float64(numx) - 1)
-	ygap := disty / (float64(numy) - 1)
+	if a.CurrentJob == nil {
+		return 0, 0, 0, 0, errors.New("actions: bounding box: no g-code loaded")
+	}
 
-	fmt.Println(numx, numy, maxx, maxy)
+	return a.CurrentJob.GetBoundingBox(a.jobState())
+}
+
+func (a *Actions) AutoLevel(ctx context.Context, grid *autolevel.Grid) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
 
-	pts := make([][]*point.Point, numy)
-	y := miny
+	if a.CurrentJob == nil {
+		return errors.New("actions: autolevel: no g-code loaded")
+	}
 
-	for j := 0; j < numy; j++ {
-		pts[j] = make([]*point.Point, numx)
+	if grid == nil {
+		return errors.New("actions: autolevel: no grid defined")
+	}
 
-		if j%2 == 0 {
-			x := minx
-			for i := 0; i < numx; i++ {
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-				}
+	pts := make([][]*point.Point, grid.NY)
+	for j := range pts {
+		pts[j] = make([]*point.Point, grid.NX)
+	}
 
-				if err := a.autoLevelProbe(ctx, x, y); err != nil {
-					return err
-				}
-				pts[j][i] = a.Grbl.LastProbe
+	// probe rows alternating directions, to minimize travel
+	for j := 0; j < grid.NY; j++ {
+		for k := 0; k < grid.NX; k++ {
+			i := k
+			if j%2 != 0 {
+				i = grid.NX - 1 - k
+			}
 
-				x += xgap
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
 			}
-		} else {
-			x := maxx
-			for i := numx - 1; i >= 0; i-- {
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-				}
-
-				if err := a.autoLevelProbe(ctx, x, y); err != nil {
-					return err
-				}
-				pts[j][i] = a.Grbl.LastProbe
-
-				x -= xgap
+
+			x, y := grid.Point(i, j)
+			p, err := a.autoLevelProbe(ctx, x, y)
+			if err != nil {
+				return err
 			}
+			pts[j][i] = p
 		}
-
-		y += ygap
 	}
 
-	fp, err := os.OpenFile(a.CurrentJobFile+".json", os.O_RDWR|os.O_CREATE, 0755)
+	fp, err := os.OpenFile(a.CurrentJobFile+".json", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
 		return err
 	}
 	defer fp.Close()
 
+	a.Grbl.RLock()
+	wco := a.Grbl.WCO
+	a.Grbl.RUnlock()
+
 	if err := json.NewEncoder(fp).Encode(map[string]interface{}{
-		"wco":    a.Grbl.WCO,
+		"wco":    wco,
 		"points": pts,
 	}); err != nil {
 		return err
 	}
 
-	return a.autoLevelLoadProbe(pts, a.Grbl.WCO)
+	return a.autoLevelLoadProbe(pts, wco)
 }
 
 func (a *Actions) AutoLevelLoad(ctx context.Context) error {
@@ -279,7 +417,11 @@ func (a *Actions) AutoLevelLoad(ctx context.Context) error {
 		return err
 	}
 
-	if a.Grbl.WCO != nil && !a.Grbl.WCO.Equals(data.WCO) {
+	a.Grbl.RLock()
+	wco := a.Grbl.WCO
+	a.Grbl.RUnlock()
+
+	if wco != nil && !wco.Equals(data.WCO) {
 		return errors.New("actions: autolevel-load: stored WCO differs from current WCO")
 	}
 
@@ -295,15 +437,49 @@ func (a *Actions) Start(ctx context.Context) error {
 		return errors.New("actions: start: no g-code loaded")
 	}
 
-	if len(a.Probe) > 0 {
-		g, err := autolevel.AutoLevel(a.CurrentJob, a.ProbeSpline, *a.Grbl.GCodeState)
-		if err != nil {
-			return err
-		}
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return err
+	}
 
-		log.Print("autolevel enabled")
-		return a.Grbl.SendJob(ctx, g)
+	a.Grbl.RLock()
+	known := a.Grbl.GCodeState != nil
+	a.Grbl.RUnlock()
+
+	if !known {
+		return errors.New("actions: start: gcode state unknown")
+	}
+
+	st := a.jobState()
+	origin, err := a.prepareJob(st)
+	if err != nil {
+		return err
+	}
+
+	// reject the job before the spindle is turned on
+	if err := preflight(origin); err != nil {
+		return err
 	}
 
-	return a.Grbl.SendJob(ctx, a.CurrentJob)
+	a.progress.reset(origin.distances)
+	defer a.progress.stop()
+
+	a.running = origin
+	a.runningState = st
+
+	toolChange := a.Grbl.ToolChange
+	a.Grbl.ToolChange = a.handleToolChange
+	defer func() {
+		a.Grbl.ToolChange = toolChange
+	}()
+
+	return a.Grbl.SendSourceWithErrorHandler(ctx, origin.Source(), a.progress.ack, a.lineErrorHandler(0, 0))
+}
+
+// RunningJobLines returns the number of lines of the job last sent by
+// Start, with autolevel applied, or 0 if none.
+func (a *Actions) RunningJobLines() int {
+	if a == nil || a.running == nil {
+		return 0
+	}
+	return a.running.len()
 }
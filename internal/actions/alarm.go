@@ -0,0 +1,165 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+// distance the probe is retracted after a probe failure, in mm.
+const alarmProbeRetract = 5.
+
+// RecoveryAction is the action needed to unlock grbl after an alarm.
+type RecoveryAction int
+
+const (
+	RecoveryHome   RecoveryAction = iota // machine position lost, re-home
+	RecoveryUnlock                       // machine position retained, unlock
+	RecoveryProbe                        // unlock and retract the probe
+)
+
+// Recovery describes how to recover from the current alarm.
+type Recovery struct {
+	// Alarm is nil if grbl is locked since it was started, waiting for
+	// homing.
+	Alarm       *response.Alarm
+	Action      RecoveryAction
+	Description string
+}
+
+func newRecovery(alarm *response.Alarm) *Recovery {
+	rv := &Recovery{
+		Alarm: alarm,
+	}
+
+	if alarm == nil {
+		rv.Action = RecoveryHome
+		rv.Description = "grbl requires homing after startup."
+		return rv
+	}
+
+	switch alarm.Code {
+	case response.AlarmHardLimitError:
+		rv.Action = RecoveryHome
+		rv.Description = "a limit switch was triggered and the machine position is lost. re-home the machine, and zero the work coordinates again."
+	case response.AlarmSoftLimitError:
+		rv.Action = RecoveryUnlock
+		rv.Description = "the job moves beyond the machine travel, and was stopped before moving. the machine position was retained, check the work zero and the job bounds."
+	case response.AlarmAbortCycle:
+		rv.Action = RecoveryHome
+		rv.Description = "grbl was reset while moving and the machine position is likely lost. re-home the machine, and zero the work coordinates again."
+	case response.AlarmProbeFailInitial:
+		rv.Action = RecoveryProbe
+		rv.Description = "the probe was triggered before probing. check that the probe clip isn't touching the tool or the workpiece, and the probe wiring."
+	case response.AlarmProbeFailContact:
+		rv.Action = RecoveryProbe
+		rv.Description = "the probe didn't touch the workpiece. check that the probe clip is attached to the tool, and the probe wiring."
+	default:
+		rv.Action = RecoveryHome
+		rv.Description = "homing failed. check the limit switches and the homing settings, and re-home the machine."
+	}
+	return rv
+}
+
+// Alarm returns how to recover from the current alarm, or nil if grbl is
+// not in alarm state.
+func (a *Actions) Alarm(ctx context.Context) (*Recovery, error) {
+	if a == nil || a.Grbl == nil {
+		return nil, ErrGrblNotSet
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return nil, err
+	}
+
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
+	if a.Grbl.State != response.StateAlarm {
+		return nil, nil
+	}
+	return newRecovery(a.Grbl.LastAlarm), nil
+}
+
+// Recover unlocks grbl after an alarm, re-homing the machine or retracting
+// the probe when required.
+func (a *Actions) Recover(ctx context.Context, r *Recovery) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	if r == nil {
+		return errors.New("actions: recover: grbl is not in alarm state")
+	}
+
+	switch r.Action {
+	case RecoveryHome:
+		if err := a.Grbl.RefreshSettings(ctx); err != nil {
+			return err
+		}
+
+		a.Grbl.RLock()
+		homing := a.Grbl.Settings[22] != 0
+		a.Grbl.RUnlock()
+
+		if homing {
+			log.Print("recover: homing")
+			if err := a.Grbl.SendCommands(ctx, "$H"); err != nil {
+				return err
+			}
+			break
+		}
+
+		log.Print("recover: homing disabled ($22), unlocking. the machine position may be wrong")
+		if err := a.Grbl.SendCommands(ctx, "$X"); err != nil {
+			return err
+		}
+
+	case RecoveryUnlock:
+		log.Print("recover: unlocking")
+		if err := a.Grbl.SendCommands(ctx, "$X"); err != nil {
+			return err
+		}
+
+	case RecoveryProbe:
+		log.Printf("recover: unlocking and retracting the probe by %.3f mm", alarmProbeRetract)
+		if err := a.Grbl.SendCommands(ctx, "$X"); err != nil {
+			return err
+		}
+
+		// the retract distance is in mm, restore the units and distance
+		// mode of grbl afterwards.
+		restore := "G90"
+		a.Grbl.RLock()
+		if st := a.Grbl.GCodeState; st != nil {
+			restore = fmt.Sprintf("G%.0f G%.0f", st.Units, st.Distance)
+		}
+		a.Grbl.RUnlock()
+
+		if err := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf("G21 G91\nG00 Z%.3f\n%s\nG04 P0.001", alarmProbeRetract, restore)); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("actions: recover: invalid action: %d", r.Action)
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return err
+	}
+
+	a.Grbl.Lock()
+	defer a.Grbl.Unlock()
+
+	if a.Grbl.State == response.StateAlarm {
+		return errors.New("actions: recover: grbl is still in alarm state")
+	}
+
+	// the alarm is solved, don't report it again if grbl is locked by a
+	// power cycle.
+	a.Grbl.LastAlarm = nil
+	return nil
+}
@@ -0,0 +1,40 @@
+package actions
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRecoverProbeInches(t *testing.T) {
+	ctx := testContext(t)
+	a, _ := newSimActions(t, nil)
+
+	// probing away from the surface fails with an alarm
+	if err := a.Grbl.SendGCodeInline(ctx, "G20 G90\nG0 X0 Y0 Z0\nG38.2 Z0.1 F10"); err == nil {
+		t.Fatal("expected probe alarm")
+	}
+
+	r, err := a.Alarm(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.Action != RecoveryProbe {
+		t.Fatalf("unexpected recovery: %+v", r)
+	}
+	if err := a.Recover(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, ctx, a)
+
+	a.Grbl.RLock()
+	wpos := a.Grbl.WPos.Copy()
+	st := *a.Grbl.GCodeState
+	a.Grbl.RUnlock()
+
+	if want := 2.54 + alarmProbeRetract; math.Abs(wpos.Z-want) > 1e-3 {
+		t.Errorf("got Z=%.3f after retract, want Z=%.3f", wpos.Z, want)
+	}
+	if st.Units != 20 || st.Distance != 90 {
+		t.Errorf("modal state not restored: G%.0f G%.0f", st.Units, st.Distance)
+	}
+}
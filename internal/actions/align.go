@@ -0,0 +1,189 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// maximum difference between the distance of the alignment pins in the
+// job and in the machine, in mm.
+const alignTolerance = 0.5
+
+type alignPin struct {
+	// position of the pin in the job, as loaded, and measured in machine
+	// coordinates.
+	job     *point.Point
+	machine *point.Point
+}
+
+// alignment is the transform applied to the job for double-sided boards.
+type alignment struct {
+	// job as loaded, before any transform
+	job    gcode.Job
+	mirror gcode.Transform
+	pins   [2]*alignPin
+}
+
+func (a *Actions) transformState() gcode.State {
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
+	if a.Grbl.GCodeState != nil {
+		return *a.Grbl.GCodeState
+	}
+	return *gcode.NewState()
+}
+
+// getAlignment returns the alignment of the current job, keeping a copy
+// of it before any transform.
+func (a *Actions) getAlignment() *alignment {
+	if a.alignment == nil {
+		a.alignment = &alignment{
+			job:    a.CurrentJob,
+			mirror: gcode.IdentityTransform(),
+		}
+	}
+	return a.alignment
+}
+
+func (a *Actions) transformJob(t gcode.Transform) error {
+	j, err := gcode.TransformJob(a.getAlignment().job, a.transformState(), t)
+	if err != nil {
+		return err
+	}
+
+	a.CurrentJob = j
+	return nil
+}
+
+// Mirror mirrors the loaded job around a vertical (axis 'X') or horizontal
+// (axis 'Y') line at the given position, in mm, to mill the bottom side of
+// double-sided boards. any alignment done before is discarded.
+func (a *Actions) Mirror(axis rune, v float64) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	if a.CurrentJob == nil {
+		return errors.New("actions: mirror: no g-code loaded")
+	}
+
+	var t gcode.Transform
+	switch axis {
+	case 'X':
+		t = gcode.MirrorX(v)
+	case 'Y':
+		t = gcode.MirrorY(v)
+	default:
+		return fmt.Errorf("actions: mirror: invalid axis: %c", axis)
+	}
+
+	if err := a.transformJob(t); err != nil {
+		return err
+	}
+	a.alignment.mirror = t
+
+	log.Printf("mirror: job mirrored around %c=%.3f", axis, v)
+	return nil
+}
+
+// AlignPin records the current machine position as the position of an
+// alignment pin (1 or 2), that is at x, y in the job, as loaded.
+func (a *Actions) AlignPin(ctx context.Context, pin int, x float64, y float64) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	if a.CurrentJob == nil {
+		return errors.New("actions: align: no g-code loaded")
+	}
+
+	if pin != 1 && pin != 2 {
+		return fmt.Errorf("actions: align: invalid pin: %d", pin)
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return err
+	}
+
+	a.Grbl.RLock()
+	mpos := a.Grbl.MPos
+	a.Grbl.RUnlock()
+
+	if mpos == nil {
+		return errors.New("actions: align: machine position unknown")
+	}
+
+	a.getAlignment().pins[pin-1] = &alignPin{
+		job:     &point.Point{X: x, Y: y},
+		machine: mpos.Copy(),
+	}
+
+	log.Printf("align: pin %d at X=%.3f,Y=%.3f recorded at machine position %s", pin, x, y, mpos)
+	return nil
+}
+
+// Align computes the rotation and translation that move the alignment
+// pins of the (mirrored) job to their recorded positions, and applies it
+// to the job. it returns the rotation angle, in degrees.
+func (a *Actions) Align(ctx context.Context) (float64, error) {
+	if a == nil || a.Grbl == nil {
+		return 0, ErrGrblNotSet
+	}
+
+	if a.alignment == nil || a.alignment.pins[0] == nil || a.alignment.pins[1] == nil {
+		return 0, errors.New("actions: align: alignment pins not recorded")
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return 0, err
+	}
+
+	a.Grbl.RLock()
+	wco := a.Grbl.WCO
+	a.Grbl.RUnlock()
+
+	if wco == nil {
+		return 0, errors.New("actions: align: work coordinate offset unknown")
+	}
+
+	// expected positions in the mirrored job, and measured positions in
+	// the current work coordinates.
+	var exp, meas [2][2]float64
+	for i, pin := range a.alignment.pins {
+		exp[i][0], exp[i][1] = a.alignment.mirror.Apply(pin.job.X, pin.job.Y)
+		meas[i][0], meas[i][1] = pin.machine.X-wco.X, pin.machine.Y-wco.Y
+	}
+
+	expDist := math.Hypot(exp[1][0]-exp[0][0], exp[1][1]-exp[0][1])
+	measDist := math.Hypot(meas[1][0]-meas[0][0], meas[1][1]-meas[0][1])
+	if expDist == 0 {
+		return 0, errors.New("actions: align: alignment pins at the same position")
+	}
+	if math.Abs(expDist-measDist) > alignTolerance {
+		return 0, fmt.Errorf("actions: align: distance between pins differs: %.3f mm in the job, %.3f mm measured", expDist, measDist)
+	}
+
+	angle := math.Atan2(meas[1][1]-meas[0][1], meas[1][0]-meas[0][0]) - math.Atan2(exp[1][1]-exp[0][1], exp[1][0]-exp[0][0])
+	angle = math.Remainder(angle, 2*math.Pi)
+
+	// rotate around the middle point between the pins, to split the
+	// measurement errors between them.
+	cx, cy := (exp[0][0]+exp[1][0])/2, (exp[0][1]+exp[1][1])/2
+	mx, my := (meas[0][0]+meas[1][0])/2, (meas[0][1]+meas[1][1])/2
+
+	t := a.alignment.mirror.Then(gcode.Rotation(angle, cx, cy)).Then(gcode.Translation(mx-cx, my-cy))
+	if err := a.transformJob(t); err != nil {
+		return 0, err
+	}
+
+	deg := angle * 180 / math.Pi
+	log.Printf("align: job rotated by %.3f degrees and moved by X=%.3f,Y=%.3f", deg, mx-cx, my-cy)
+	return deg, nil
+}
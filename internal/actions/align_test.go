@@ -0,0 +1,162 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+const alignJob = "G21 G90\nG0 X0 Y0 Z1\nG1 X10 Y0 F100\nG2 X20 Y0 I5\n"
+
+// alignPins moves the machine to each position and records it as the
+// position of the pin at the given job position.
+func alignPins(t *testing.T, ctx context.Context, a *Actions, pins [2][4]float64) {
+	t.Helper()
+
+	for i, p := range pins {
+		if err := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf("G0 X%.4f Y%.4f\nG4 P0.001", p[2], p[3])); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.AlignPin(ctx, i+1, p[0], p[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkJob(t *testing.T, a *Actions, want string) {
+	t.Helper()
+
+	j, err := gcode.NewJobFromData(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, st, wst := a.CurrentJob, gcode.NewState(), gcode.NewState()
+	if len(got) != len(j) {
+		t.Fatalf("got job:\n%s\nwant:\n%s", got, j)
+	}
+	for i := range got {
+		m, err := st.Process(got[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		wm, err := wst.Process(j[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m == nil || wm == nil {
+			continue
+		}
+		if m.Mode != wm.Mode || math.Abs(m.End.X-wm.End.X) > 1e-3 || math.Abs(m.End.Y-wm.End.Y) > 1e-3 {
+			t.Fatalf("line %d: got %s, want %s\njob:\n%s", i+1, got[i], j[i], got)
+		}
+		if m.Arc != nil && (math.Abs(m.Arc.Center.X-wm.Arc.Center.X) > 1e-3 || math.Abs(m.Arc.Center.Y-wm.Arc.Center.Y) > 1e-3) {
+			t.Fatalf("line %d: got arc center %s, want %s", i+1, m.Arc.Center, wm.Arc.Center)
+		}
+	}
+}
+
+func TestAlign(t *testing.T) {
+	ctx := testContext(t)
+	a, _ := newSimActions(t, nil)
+	loadJob(t, ctx, a, alignJob)
+
+	if err := a.Grbl.SendGCodeInline(ctx, "G21 G90 G0 Z5"); err != nil {
+		t.Fatal(err)
+	}
+
+	// pins at (0, 0) and (20, 0) in the job, found rotated by 90 degrees
+	// around (5, 5)
+	alignPins(t, ctx, a, [2][4]float64{
+		{0, 0, 5, 5},
+		{20, 0, 5, 25},
+	})
+
+	deg, err := a.Align(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(deg-90) > 1e-3 {
+		t.Errorf("got rotation %.3f degrees, want 90", deg)
+	}
+	checkJob(t, a, "G21 G90\nG0 X5 Y5 Z1\nG1 X5 Y15 F100\nG2 X5 Y25 J5\n")
+
+	// aligning again starts from the job as loaded
+	alignPins(t, ctx, a, [2][4]float64{
+		{0, 0, 1, 1},
+		{20, 0, 21, 1},
+	})
+	deg, err = a.Align(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(deg) > 1e-3 {
+		t.Errorf("got rotation %.3f degrees, want 0", deg)
+	}
+	checkJob(t, a, "G21 G90\nG0 X1 Y1 Z1\nG1 X11 Y1 F100\nG2 X21 Y1 I5\n")
+}
+
+func TestAlignMirror(t *testing.T) {
+	ctx := testContext(t)
+	a, _ := newSimActions(t, nil)
+	loadJob(t, ctx, a, alignJob)
+
+	if err := a.Grbl.SendGCodeInline(ctx, "G21 G90 G0 Z5"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bottom side, mirrored around X=10, swaps the pins
+	if err := a.Mirror('X', 10); err != nil {
+		t.Fatal(err)
+	}
+	checkJob(t, a, "G21 G90\nG0 X20 Y0 Z1\nG1 X10 Y0 F100\nG3 X0 Y0 I-5\n")
+
+	// the pins are still referred by their position in the job as
+	// loaded, found slightly rotated
+	angle := 2 * math.Pi / 180
+	alignPins(t, ctx, a, [2][4]float64{
+		{0, 0, 10 + 10*math.Cos(angle), 10 + 10*math.Sin(angle)},
+		{20, 0, 10 - 10*math.Cos(angle), 10 - 10*math.Sin(angle)},
+	})
+
+	deg, err := a.Align(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(deg-2) > 1e-3 {
+		t.Errorf("got rotation %.3f degrees, want 2", deg)
+	}
+
+	x, y := 10+10*math.Cos(angle), 10+10*math.Sin(angle)
+	checkJob(t, a, fmt.Sprintf("G21 G90\nG0 X%.4f Y%.4f Z1\nG1 X10 Y10 F100\nG3 X%.4f Y%.4f I%.4f J%.4f\n",
+		x, y, 20-x, 20-y, -5*math.Cos(angle), -5*math.Sin(angle)))
+}
+
+func TestAlignErrors(t *testing.T) {
+	ctx := testContext(t)
+	a, _ := newSimActions(t, nil)
+	loadJob(t, ctx, a, alignJob)
+
+	if _, err := a.Align(ctx); err == nil {
+		t.Error("expected error without pins")
+	}
+
+	// the distance between the pins doesn't match the job
+	alignPins(t, ctx, a, [2][4]float64{
+		{0, 0, 0, 0},
+		{20, 0, 21, 0},
+	})
+	if _, err := a.Align(ctx); err == nil {
+		t.Error("expected error for pins distance")
+	}
+
+	if err := a.AlignPin(ctx, 3, 0, 0); err == nil {
+		t.Error("expected error for invalid pin")
+	}
+	if err := a.Mirror('Z', 0); err == nil {
+		t.Error("expected error for invalid axis")
+	}
+}
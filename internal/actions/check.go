@@ -0,0 +1,130 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+// CheckResult is the result of a job validated by grbl's check mode.
+type CheckResult struct {
+	Lines  int
+	Errors []*JobError
+}
+
+// Check sends the current job, with autolevel applied if enabled, to grbl
+// in check mode, that validates the lines without moving the machine. all
+// the errors reported are collected, instead of stopping at the first one.
+// grbl is reset to normal mode afterwards.
+func (a *Actions) Check(ctx context.Context) (rv *CheckResult, err error) {
+	if a == nil || a.Grbl == nil {
+		return nil, ErrGrblNotSet
+	}
+
+	if a.CurrentJob == nil {
+		return nil, errors.New("actions: check: no g-code loaded")
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return nil, err
+	}
+
+	a.Grbl.RLock()
+	state, stateName := a.Grbl.State, a.Grbl.StateName
+	known := a.Grbl.GCodeState != nil
+	a.Grbl.RUnlock()
+
+	if state != response.StateIdle {
+		return nil, fmt.Errorf("actions: check: grbl is not idle: %s", stateName)
+	}
+	if !known {
+		return nil, errors.New("actions: check: gcode state unknown")
+	}
+
+	st := a.jobState()
+	origin, err := a.prepareJob(st)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.Grbl.SetCheckMode(ctx, true); err != nil {
+		return nil, err
+	}
+	log.Print("check: check mode enabled")
+
+	defer func() {
+		// the reset that disables the check mode restores the default
+		// modal state
+		cerr := a.Grbl.SetCheckMode(context.Background(), false)
+		if cerr == nil {
+			log.Print("check: check mode disabled")
+			cerr = a.Grbl.SendGCodeInline(context.Background(), fmt.Sprintf("G%.0f\n%s", st.WCS, modalPreamble(&st)))
+		}
+		if cerr != nil && err == nil {
+			rv, err = nil, cerr
+		}
+	}()
+
+	// the lines refused by the preflight check are not sent, as some of
+	// them abort the job when streaming.
+	rv = &CheckResult{
+		Lines:  origin.len(),
+		Errors: append([]*JobError{}, origin.refused...),
+	}
+	refused := map[int]bool{}
+	for _, jerr := range origin.refused {
+		refused[jerr.Line-1] = true
+	}
+	src := &skipSource{
+		src:   origin.Source(),
+		skip:  refused,
+		index: -1,
+	}
+
+	// tool changes would wait for the operator
+	toolChange := a.Grbl.ToolChange
+	a.Grbl.ToolChange = nil
+	defer func() {
+		a.Grbl.ToolChange = toolChange
+	}()
+
+	onError := func(ctx context.Context, err *grbl.LineError) error {
+		rv.Errors = append(rv.Errors, origin.jobError(err.Index, err.Err))
+		return nil
+	}
+	if err := a.Grbl.SendSourceWithErrorHandler(ctx, src, nil, onError); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rv.Errors, func(i int, j int) bool {
+		return rv.Errors[i].Line < rv.Errors[j].Line
+	})
+	return rv, nil
+}
+
+// skipSource replaces the lines with the given indexes by empty lines,
+// that are not sent to grbl.
+type skipSource struct {
+	src   gcode.Source
+	skip  map[int]bool
+	index int
+}
+
+func (s *skipSource) Next() (gcode.Line, error) {
+	l, err := s.src.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	s.index++
+	if s.skip[s.index] {
+		return gcode.Line{}, nil
+	}
+	return l, nil
+}
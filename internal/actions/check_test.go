@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+func TestCheck(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		ctx := testContext(t)
+		a, _ := newSimActions(t, nil)
+		a.Grbl.Streaming = streaming
+
+		long := "G1"
+		for _, c := range "XYZFSPRIJKL" {
+			long += fmt.Sprintf(" %c100000.1234", c)
+		}
+		loadJob(t, ctx, a, "G21 G90\nG0 X0 Y0 Z1\nG1 X1 F100\n"+long+"\nG2 X20 Y0 R1\nT2 M6\nG1 X3\n")
+
+		if err := a.Grbl.SendGCodeInline(ctx, "G55 G20 G91 F10"); err != nil {
+			t.Fatal(err)
+		}
+
+		toolChange := func(ctx context.Context, l gcode.Line) error { return nil }
+		a.Grbl.ToolChange = toolChange
+
+		r, err := a.Check(ctx)
+		if err != nil {
+			t.Fatalf("streaming=%t: %s", streaming, err)
+		}
+
+		if r.Lines != 7 {
+			t.Errorf("streaming=%t: got %d lines, want 7", streaming, r.Lines)
+		}
+		lines := []int{}
+		for _, jerr := range r.Errors {
+			lines = append(lines, jerr.FileLine)
+		}
+		if len(lines) != 2 || lines[0] != 4 || lines[1] != 5 {
+			t.Errorf("streaming=%t: unexpected errors: %v", streaming, r.Errors)
+		}
+
+		a.Grbl.RLock()
+		st := *a.Grbl.GCodeState
+		restored := a.Grbl.ToolChange != nil
+		a.Grbl.RUnlock()
+
+		if st.WCS != 55 || st.Units != 20 || st.Distance != 91 || st.FromMM(st.Feed) != 10 {
+			t.Errorf("streaming=%t: modal state not restored: %s", streaming, &st)
+		}
+		if !restored {
+			t.Errorf("streaming=%t: tool change handler not restored", streaming)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/interp2d"
+)
+
+// ErrorPolicy defines what happens when grbl reports an error for a line
+// of the running job.
+type ErrorPolicy int
+
+const (
+	ErrorPolicyAbort ErrorPolicy = iota // stop sending the job
+	ErrorPolicyPause                    // wait for the operator to skip the line or abort
+	ErrorPolicySkip                     // log the error and continue
+)
+
+var errorPolicyNames = []string{"abort", "pause", "skip"}
+
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	for i, name := range errorPolicyNames {
+		if s == name {
+			return ErrorPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("actions: invalid error policy: %s", s)
+}
+
+func (p ErrorPolicy) String() string {
+	if p >= 0 && int(p) < len(errorPolicyNames) {
+		return errorPolicyNames[p]
+	}
+	return fmt.Sprintf("unknown (%d)", int(p))
+}
+
+// JobError is an error reported by grbl for a line of the running job.
+type JobError struct {
+	Line     int    // line of the running job, 1-based, as used by Resume
+	FileLine int    // line of the g-code file, 1-based, or 0 if unknown
+	Text     string // text of the line, as loaded from the file
+	Err      error
+}
+
+func (e *JobError) Error() string {
+	if e.FileLine > 0 {
+		return fmt.Sprintf("actions: job line %d (file line %d: %s): %s", e.Line, e.FileLine, e.Text, e.Err)
+	}
+	return fmt.Sprintf("actions: job line %d (%s): %s", e.Line, e.Text, e.Err)
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// PreflightError lists the lines of a job that grbl would refuse.
+type PreflightError struct {
+	Errors []*JobError
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("actions: preflight: %d lines would be refused by grbl, first: %s", len(e.Errors), e.Errors[0])
+}
+
+// readJobFile loads a g-code file, returning the file line number of each
+// line of the job. the job is kept in memory, instead of read again from
+// the file, as align replaces it with a transformed job.
+func readJobFile(fname string) (gcode.Job, []int, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fp.Close()
+
+	r := gcode.NewReader(fp)
+	j, lines := gcode.Job{}, []int{}
+	for {
+		l, err := r.Next()
+		if err == io.EOF {
+			return j, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		j = append(j, l)
+		lines = append(lines, r.LineNumber())
+	}
+}
+
+// indexSource records the index of the last line read from a job.
+type indexSource struct {
+	src   gcode.Source
+	index int
+}
+
+func (s *indexSource) Next() (gcode.Line, error) {
+	l, err := s.src.Next()
+	if err == nil {
+		s.index++
+	}
+	return l, err
+}
+
+// originSource records the index of the original line of each line read
+// from a leveled job. the leveled lines are not kept, as they can be many
+// times more than the original ones.
+type originSource struct {
+	src   gcode.Source
+	in    *indexSource
+	index []int
+}
+
+func (s *originSource) Next() (gcode.Line, error) {
+	l, err := s.src.Next()
+	if err == nil {
+		s.index = append(s.index, s.in.index)
+	}
+	return l, err
+}
+
+// jobOrigin maps the lines of a job sent to grbl to the g-code file. the
+// lines sent are generated again by Source when needed.
+type jobOrigin struct {
+	source gcode.Job // job as loaded, before autolevel
+	index  []int     // index in source of each line, nil if the same
+	lines  []int     // file line number of each line of source
+
+	// autolevel settings, spline is nil if disabled
+	spline *interp2d.Spline
+	state  gcode.State
+	cfg    autolevel.Config
+
+	refused   []*JobError // lines that grbl would refuse
+	distances []float64   // motion distance covered after each line
+}
+
+// Source returns the lines of the job as sent to grbl.
+func (o *jobOrigin) Source() gcode.Source {
+	if o.spline == nil {
+		return o.source.Source()
+	}
+	return autolevel.NewSource(o.source.Source(), o.spline, o.state, o.cfg)
+}
+
+// len returns the number of lines of the job as sent to grbl.
+func (o *jobOrigin) len() int {
+	if o.index != nil {
+		return len(o.index)
+	}
+	return len(o.source)
+}
+
+// jobError returns the error for a line of the job, with its origin in the
+// g-code file.
+func (o *jobOrigin) jobError(index int, err error) *JobError {
+	rv := &JobError{
+		Line: index + 1,
+		Err:  err,
+	}
+	if o == nil || index < 0 {
+		return rv
+	}
+
+	src := index
+	if o.index != nil {
+		if index >= len(o.index) {
+			return rv
+		}
+		src = o.index[index]
+	}
+	if src >= len(o.source) {
+		return rv
+	}
+
+	rv.Text = o.source[src].Text()
+	if src < len(o.lines) {
+		rv.FileLine = o.lines[src]
+	}
+	return rv
+}
+
+// prepareJob returns the current job as it is sent to grbl, with autolevel
+// applied if enabled. the job is walked once, to map the leveled lines to
+// the original ones, to compute the motion distances and to look for the
+// lines that grbl would refuse.
+func (a *Actions) prepareJob(st gcode.State) (*jobOrigin, error) {
+	origin := &jobOrigin{
+		source: a.CurrentJob,
+		lines:  a.currentLines,
+	}
+
+	var src gcode.Source = a.CurrentJob.Source()
+	var osrc *originSource
+	if len(a.Probe) > 0 {
+		origin.spline = a.ProbeSpline
+		origin.state = st
+		origin.cfg = a.AutoLevelCfg
+
+		in := &indexSource{
+			src:   src,
+			index: -1,
+		}
+		osrc = &originSource{
+			src:   autolevel.NewSource(in, origin.spline, st, origin.cfg),
+			in:    in,
+			index: []int{},
+		}
+		src = osrc
+		log.Print("autolevel enabled")
+	}
+
+	dsrc := &distanceSource{
+		src: src,
+		st:  st,
+	}
+	lerrs, err := grbl.Preflight(dsrc)
+	if err != nil {
+		return nil, err
+	}
+
+	if osrc != nil {
+		origin.index = osrc.index
+	}
+	origin.distances = dsrc.distances
+	for _, lerr := range lerrs {
+		origin.refused = append(origin.refused, origin.jobError(lerr.Index, lerr.Err))
+	}
+	return origin, nil
+}
+
+// preflight returns an error listing the lines of a job that grbl would
+// refuse, if any.
+func preflight(origin *jobOrigin) error {
+	if len(origin.refused) == 0 {
+		return nil
+	}
+	return &PreflightError{
+		Errors: origin.refused,
+	}
+}
+
+type linePause struct {
+	sync.Mutex
+	err  *JobError
+	skip chan bool
+}
+
+// LineErrorPending returns the error of the running job waiting for the
+// operator, or nil.
+func (a *Actions) LineErrorPending() *JobError {
+	a.linePause.Lock()
+	defer a.linePause.Unlock()
+
+	return a.linePause.err
+}
+
+// LineErrorContinue notifies the running job paused by an error to skip
+// the failed line and continue, or to abort.
+func (a *Actions) LineErrorContinue(skip bool) error {
+	a.linePause.Lock()
+	defer a.linePause.Unlock()
+
+	if a.linePause.err == nil {
+		return errors.New("actions: job: no error pending")
+	}
+
+	a.linePause.err = nil
+	a.linePause.skip <- skip
+	return nil
+}
+
+func (a *Actions) waitLineError(ctx context.Context, jerr *JobError) bool {
+	a.linePause.Lock()
+	a.linePause.err = jerr
+	a.linePause.skip = make(chan bool, 1)
+	skip := a.linePause.skip
+	a.linePause.Unlock()
+
+	select {
+	case rv := <-skip:
+		return rv
+	case <-ctx.Done():
+		a.linePause.Lock()
+		a.linePause.err = nil
+		a.linePause.Unlock()
+		return false
+	}
+}
+
+// lineErrorHandler returns the grbl error handler for a job that starts
+// with offset lines that are not part of the running job, followed by the
+// lines of the running job from index start.
+func (a *Actions) lineErrorHandler(start int, offset int) grbl.LineErrorHandler {
+	return func(ctx context.Context, err *grbl.LineError) error {
+		if err.Index < offset {
+			return err
+		}
+		jerr := a.running.jobError(start+err.Index-offset, err.Err)
+
+		switch a.ErrorPolicy {
+		case ErrorPolicySkip:
+			log.Printf("job: skipping line: %s", jerr)
+			return nil
+
+		case ErrorPolicyPause:
+			log.Printf("job: %s", jerr)
+			log.Print("job: paused, press 's' to skip the line or 'a' to abort")
+			if a.waitLineError(ctx, jerr) {
+				log.Printf("job: skipping line %d", jerr.Line)
+				return nil
+			}
+		}
+
+		return jerr
+	}
+}
@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/interp2d"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+func TestPrepareJobLeveled(t *testing.T) {
+	j, err := gcode.NewJobFromData("G21 G90\nG0 X0 Y0 Z0\nG1 X10 F100\nM100\nG1 Y10\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe := [][]*point.Point{
+		{{X: -50, Y: -50}, {X: 50, Y: -50}},
+		{{X: -50, Y: 50}, {X: 50, Y: 50}},
+	}
+	spline, err := interp2d.NewSpline(probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spline.Close()
+
+	cfg := autolevel.DefaultConfig()
+	cfg.MaxSegmentLength = 5
+
+	a := &Actions{
+		CurrentJob:   j,
+		Probe:        probe,
+		ProbeSpline:  spline,
+		AutoLevelCfg: cfg,
+		currentLines: []int{1, 3, 4, 6, 7},
+	}
+
+	origin, err := a.prepareJob(*gcode.NewState())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the moves of 10 mm are split in 2 segments
+	wantIndex := []int{0, 1, 2, 2, 3, 4, 4}
+	if origin.len() != len(wantIndex) {
+		t.Fatalf("got %d lines, want %d", origin.len(), len(wantIndex))
+	}
+	for i, index := range origin.index {
+		if index != wantIndex[i] {
+			t.Errorf("line %d: got index %d, want %d", i+1, index, wantIndex[i])
+		}
+	}
+
+	wantDistances := []float64{0, 0, 5, 10, 10, 15, 20}
+	for i, d := range origin.distances {
+		if d != wantDistances[i] {
+			t.Errorf("line %d: got distance %g, want %g", i+1, d, wantDistances[i])
+		}
+	}
+
+	perr := &PreflightError{}
+	if err := preflight(origin); !errors.As(err, &perr) {
+		t.Fatalf("expected preflight error, got: %v", err)
+	}
+	if len(perr.Errors) != 1 {
+		t.Fatalf("got %d preflight errors, want 1", len(perr.Errors))
+	}
+	if jerr := perr.Errors[0]; jerr.Line != 5 || jerr.FileLine != 6 || jerr.Text != "M100" {
+		t.Errorf("unexpected preflight error: %+v", jerr)
+	}
+}
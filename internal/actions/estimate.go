@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/estimate"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+func (a *Actions) machine(ctx context.Context) (*estimate.Machine, error) {
+	a.Grbl.RLock()
+	m, err := estimate.NewMachine(a.Grbl.Settings)
+	a.Grbl.RUnlock()
+	if err == nil {
+		return m, nil
+	}
+
+	// settings are only reported on request
+	if err := a.Grbl.RefreshSettings(ctx); err != nil {
+		return nil, err
+	}
+
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+	return estimate.NewMachine(a.Grbl.Settings)
+}
+
+// Estimate estimates the time needed to run the current job, starting
+// from the current gcode state and position of grbl.
+func (a *Actions) Estimate(ctx context.Context) (*estimate.Estimate, error) {
+	if a == nil || a.Grbl == nil {
+		return nil, ErrGrblNotSet
+	}
+
+	if a.CurrentJob == nil {
+		return nil, errors.New("actions: estimate: no g-code loaded")
+	}
+
+	m, err := a.machine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return estimate.NewEstimate(a.CurrentJob.Source(), a.jobState(), m)
+}
+
+// jobState returns the gcode state a job starts from: the current gcode
+// state of grbl, with the work position for the axes it doesn't know.
+func (a *Actions) jobState() gcode.State {
+	st := gcode.NewState()
+	if a.Grbl == nil {
+		return *st
+	}
+
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
+	if a.Grbl.GCodeState != nil {
+		*st = *a.Grbl.GCodeState
+	}
+	if wpos := a.Grbl.WPos; wpos != nil {
+		if !st.Known[0] {
+			st.Position.X = wpos.X
+		}
+		if !st.Known[1] {
+			st.Position.Y = wpos.Y
+		}
+		if !st.Known[2] {
+			st.Position.Z = wpos.Z
+		}
+		st.Known = [3]bool{true, true, true}
+	}
+	return *st
+}
@@ -0,0 +1,200 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+// errorJob has an arc that grbl refuses while running it, as its radius
+// is too small, but that passes the preflight check.
+const errorJob = `G21 G90
+G0 X0 Y0 Z1
+G1 X1 F600
+(arc)
+G2 X20 Y0 R1
+G1 X2
+`
+
+func segmentsJob(n int) string {
+	rv := "G21 G90\nG0 X0 Y0 Z1\n"
+	for i := 1; i <= n; i++ {
+		rv += fmt.Sprintf("G1 X%d Y%d F600\n(segment %d)\n", 10*i, i%3, i)
+	}
+	return rv
+}
+
+func checkPosition(t *testing.T, a *Actions, x float64, y float64, z float64) {
+	t.Helper()
+
+	a.Grbl.RLock()
+	wpos := a.Grbl.WPos.Copy()
+	a.Grbl.RUnlock()
+
+	if math.Abs(wpos.X-x) > 1e-3 || math.Abs(wpos.Y-y) > 1e-3 || math.Abs(wpos.Z-z) > 1e-3 {
+		t.Errorf("got position %s, want X=%.3f,Y=%.3f,Z=%.3f", wpos, x, y, z)
+	}
+}
+
+func TestStart(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%t", streaming), func(t *testing.T) {
+			ctx := testContext(t)
+			a, _ := newSimActions(t, nil)
+			a.Grbl.Streaming = streaming
+			loadJob(t, ctx, a, segmentsJob(10))
+
+			if err := a.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			waitIdle(t, ctx, a)
+			checkPosition(t, a, 100, 1, 1)
+
+			p := a.Progress()
+			if p == nil {
+				t.Fatal("no progress")
+			}
+			if p.Lines != 22 || p.TotalLines != 22 {
+				t.Errorf("got %d/%d lines, want 22/22", p.Lines, p.TotalLines)
+			}
+			if p.Distance != p.TotalDistance || p.Percent() != 100 {
+				t.Errorf("job not completed: %s", p)
+			}
+			if last := a.LastLine(); last != 21 {
+				t.Errorf("got last line %d, want 21", last)
+			}
+		})
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		for _, tt := range []struct {
+			policy ErrorPolicy
+			skip   bool // answer to the pause
+			fail   bool
+		}{
+			{ErrorPolicyAbort, false, true},
+			{ErrorPolicySkip, false, false},
+			{ErrorPolicyPause, true, false},
+			{ErrorPolicyPause, false, true},
+		} {
+			t.Run(fmt.Sprintf("streaming=%t/%s/skip=%t", streaming, tt.policy, tt.skip), func(t *testing.T) {
+				ctx := testContext(t)
+				a, _ := newSimActions(t, nil)
+				a.Grbl.Streaming = streaming
+				a.ErrorPolicy = tt.policy
+				loadJob(t, ctx, a, errorJob)
+
+				done := make(chan error, 1)
+				go func() {
+					done <- a.Start(ctx)
+				}()
+
+				if tt.policy == ErrorPolicyPause {
+					waitFor(t, ctx, func() bool {
+						return a.LineErrorPending() != nil
+					})
+					if jerr := a.LineErrorPending(); jerr.Line != 5 || jerr.FileLine != 5 {
+						t.Errorf("unexpected pending error: %s", jerr)
+					}
+					if err := a.LineErrorContinue(tt.skip); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				err := <-done
+				if !tt.fail {
+					if err != nil {
+						t.Fatal(err)
+					}
+					waitIdle(t, ctx, a)
+					checkPosition(t, a, 2, 0, 1)
+					return
+				}
+
+				jerr := &JobError{}
+				if !errors.As(err, &jerr) {
+					t.Fatalf("expected job error, got: %v", err)
+				}
+				if jerr.Line != 5 || jerr.FileLine != 5 || jerr.Text != "G2 X20 Y0 R1" {
+					t.Errorf("unexpected job error: %s", jerr)
+				}
+
+				// when streaming, the lines sent after the failed one
+				// are executed anyway
+				if !streaming {
+					waitIdle(t, ctx, a)
+					checkPosition(t, a, 1, 0, 1)
+				}
+			})
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	ctx := testContext(t)
+	a, s := newSimActions(t, nil)
+	loadJob(t, ctx, a, "M3 S1000\n"+segmentsJob(20))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Start(ctx)
+	}()
+
+	waitFor(t, ctx, func() bool {
+		return a.LastLine() >= 10
+	})
+	s.TriggerAlarm(response.AlarmSoftLimitError)
+
+	if err := <-done; err == nil {
+		t.Fatal("expected alarm")
+	}
+
+	last := a.LastLine()
+	if last < 10 || last >= a.RunningJobLines()-1 {
+		t.Fatalf("unexpected last line: %d", last)
+	}
+
+	r, err := a.Alarm(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Recover(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	// the alarm reset the modal state of the job
+	a.Grbl.RLock()
+	spindle := a.Grbl.GCodeState.Spindle
+	a.Grbl.RUnlock()
+	if spindle != 5 {
+		t.Fatalf("spindle not stopped by the alarm: M%.0f", spindle)
+	}
+
+	if err := a.Resume(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, ctx, a)
+	checkPosition(t, a, 200, 2, 1)
+
+	if p := a.Progress(); p.Lines != p.TotalLines {
+		t.Errorf("job not completed after resume: %s", p)
+	}
+
+	// the restart point must be in range
+	if err := a.Resume(ctx, a.RunningJobLines()+1); err == nil {
+		t.Error("expected error for resume out of range")
+	}
+}
+
+func TestResumeNoJob(t *testing.T) {
+	a, _ := newSimActions(t, nil)
+	if err := a.Resume(context.Background(), 0); err == nil {
+		t.Error("expected error without a running job")
+	}
+}
@@ -0,0 +1,183 @@
+package actions
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+// Progress is the progress of the running job. as grbl acknowledges lines
+// when they are planned, it is slightly ahead of the machine.
+type Progress struct {
+	Lines      int // lines acknowledged by grbl
+	TotalLines int
+
+	// motion distance of the acknowledged lines and of the whole job, in
+	// mm.
+	Distance      float64
+	TotalDistance float64
+
+	Elapsed time.Duration
+
+	// Remaining is estimated from the speed the motion distance was
+	// covered so far, and is -1 while unknown.
+	Remaining time.Duration
+}
+
+// Percent returns the percentage of the motion distance of the job
+// already covered.
+func (p *Progress) Percent() float64 {
+	if p.TotalDistance > 0 {
+		return 100 * p.Distance / p.TotalDistance
+	}
+	if p.TotalLines > 0 {
+		return 100 * float64(p.Lines) / float64(p.TotalLines)
+	}
+	return 0
+}
+
+func (p *Progress) String() string {
+	rv := fmt.Sprintf("line %d/%d | %.1f%% | elapsed %s", p.Lines, p.TotalLines, p.Percent(), p.Elapsed.Round(time.Second))
+	if p.Remaining >= 0 {
+		rv += fmt.Sprintf(" | remaining %s", p.Remaining.Round(time.Second))
+	}
+	return rv
+}
+
+type jobProgress struct {
+	sync.Mutex
+
+	// motion distance covered after each line of the running job
+	distances []float64
+
+	started       time.Time
+	stopped       time.Time
+	startDistance float64
+	lines         int
+}
+
+// distanceSource computes the motion distance covered after each line
+// read from a job, in mm.
+type distanceSource struct {
+	src       gcode.Source
+	st        gcode.State
+	total     float64
+	distances []float64
+}
+
+func (s *distanceSource) Next() (gcode.Line, error) {
+	l, err := s.src.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	// errors are reported by grbl itself. motions from or to positions
+	// not known (e.g. after G28) are not accounted.
+	if m, err := s.st.Process(l); err == nil && m != nil && m.StartKnown && m.EndKnown {
+		s.total += m.Length()
+	}
+	s.distances = append(s.distances, s.total)
+	return l, nil
+}
+
+// reset sets the motion distances of a new running job.
+func (p *jobProgress) reset(distances []float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	if distances == nil {
+		distances = []float64{}
+	}
+	p.distances = distances
+	p.started = time.Now()
+	p.stopped = time.Time{}
+	p.startDistance = 0
+	p.lines = 0
+}
+
+// start restarts the progress counters from the given line index.
+func (p *jobProgress) start(line int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.started = time.Now()
+	p.stopped = time.Time{}
+	p.startDistance = 0
+	if line > 0 && line <= len(p.distances) {
+		p.startDistance = p.distances[line-1]
+	}
+	p.lines = line
+}
+
+func (p *jobProgress) ack(line int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.lines = line + 1
+}
+
+func (p *jobProgress) lastLine() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.lines - 1
+}
+
+// stop stops the elapsed time counter, when the job finishes or fails.
+func (p *jobProgress) stop() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.stopped = time.Now()
+}
+
+func (p *jobProgress) get() *Progress {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.distances == nil {
+		return nil
+	}
+
+	rv := &Progress{
+		Lines:      p.lines,
+		TotalLines: len(p.distances),
+		Elapsed:    time.Since(p.started),
+		Remaining:  -1,
+	}
+	if !p.stopped.IsZero() {
+		rv.Elapsed = p.stopped.Sub(p.started)
+	}
+	if len(p.distances) > 0 {
+		rv.TotalDistance = p.distances[len(p.distances)-1]
+	}
+	if p.lines > 0 {
+		rv.Distance = p.distances[p.lines-1]
+	}
+
+	if done := rv.Distance - p.startDistance; done > 0 {
+		rv.Remaining = time.Duration(float64(rv.Elapsed) * (rv.TotalDistance - rv.Distance) / done)
+	}
+
+	return rv
+}
+
+// LastLine returns the index of the last line of the running job, with
+// autolevel applied, acknowledged by grbl, or -1 if none.
+func (a *Actions) LastLine() int {
+	if a == nil {
+		return -1
+	}
+	return a.progress.lastLine()
+}
+
+// Progress returns the progress of the running job, or nil if no job was
+// started.
+func (a *Actions) Progress() *Progress {
+	if a == nil {
+		return nil
+	}
+	return a.progress.get()
+}
@@ -0,0 +1,38 @@
+package actions
+
+import (
+	"io"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+func TestProgressDistances(t *testing.T) {
+	j, err := gcode.NewJobFromData("G21 G90\nG0 X10\nG0 X0 Y0 Z0\nG1 X10 F100\nG28\nG0 X20 Y0 Z0\nG1 Y10\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &distanceSource{
+		src: j.Source(),
+		st:  *gcode.NewState(),
+	}
+	for {
+		if _, err := src.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// motions starting from unknown positions are not accounted
+	want := []float64{0, 0, 0, 10, 10, 10, 20}
+	if len(src.distances) != len(want) {
+		t.Fatalf("got %d distances, want %d", len(src.distances), len(want))
+	}
+	for i, d := range src.distances {
+		if d != want[i] {
+			t.Errorf("line %d: got distance %g, want %g", i+1, d, want[i])
+		}
+	}
+}
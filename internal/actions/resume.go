@@ -0,0 +1,206 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+const (
+	resumeSafeZ       = 2.   // mm, used if the job didn't move Z yet
+	resumePlungeFeed  = 100. // mm/min
+	resumeSpindleWait = 2.   // seconds
+)
+
+// hasAxisCommand returns true if the line has a g-code that uses its axis
+// words, instead of the current motion mode.
+func hasAxisCommand(l gcode.Line) bool {
+	if l.IsMotion() || l.IsNonModalAxis() {
+		return true
+	}
+
+	for _, f := range l {
+		if f.Letter == 'G' && (f.Value == 38.2 || f.Value == 38.3 || f.Value == 38.4 || f.Value == 38.5 || f.Value == 80) {
+			return true
+		}
+	}
+	return false
+}
+
+// resumePreamble returns the lines that take the machine from a safe
+// height to the restart point, and restore the modal state of the job.
+func resumePreamble(st *gcode.State, safeZ float64) (gcode.Job, error) {
+	g := func(v float64) string {
+		return (&gcode.Field{Letter: 'G', Value: v}).String()
+	}
+
+	data := fmt.Sprintf("%s G17 G21 G90 G94\n", g(st.WCS))
+	data += fmt.Sprintf("G00 Z%.3f\n", safeZ)
+	if st.Spindle != 5 {
+		data += fmt.Sprintf("M%.0f S%.0f\n", st.Spindle, st.Speed)
+		data += fmt.Sprintf("G04 P%.3f\n", resumeSpindleWait)
+	}
+	if st.Mist {
+		data += "M07\n"
+	}
+	if st.Flood {
+		data += "M08\n"
+	}
+	data += fmt.Sprintf("G00 X%.3f Y%.3f\n", st.Position.X, st.Position.Y)
+	data += fmt.Sprintf("G01 Z%.3f F%.3f\n", st.Position.Z, resumePlungeFeed)
+	data += modalPreamble(st)
+
+	return gcode.NewJobFromData(data)
+}
+
+// modalPreamble returns the lines that restore the plane, units, distance
+// and feed modes, the feed rate and the tool number of a job.
+func modalPreamble(st *gcode.State) string {
+	g := func(v float64) string {
+		return (&gcode.Field{Letter: 'G', Value: v}).String()
+	}
+
+	rv := fmt.Sprintf("%s %s %s %s\n", g(st.Plane), g(st.Units), g(st.Distance), g(st.FeedMode))
+	// inverse time feed rates are defined in every motion line
+	if st.FeedMode != 93 && st.Feed > 0 {
+		rv += fmt.Sprintf("F%.4f\n", st.FromMM(st.Feed))
+	}
+	rv += fmt.Sprintf("T%.0f\n", st.Tool)
+	return rv
+}
+
+// resumeSource returns the preamble lines, followed by the lines of the
+// job from the restart point, with the motion mode restored in the first
+// line that needs it.
+type resumeSource struct {
+	pre    gcode.Source
+	src    gcode.Source
+	motion *gcode.Field
+}
+
+func (s *resumeSource) Next() (gcode.Line, error) {
+	if s.pre != nil {
+		l, err := s.pre.Next()
+		if err != io.EOF {
+			return l, err
+		}
+		s.pre = nil
+	}
+
+	l, err := s.src.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.motion != nil && l.HasPosition() {
+		if !hasAxisCommand(l) {
+			l = append(gcode.Line{*s.motion}, l...)
+		}
+		s.motion = nil
+	}
+	return l, nil
+}
+
+// Resume restarts the running job from the given line number (1-based, as
+// reported by job errors). if line is 0, the job is restarted from the
+// last line acknowledged by grbl. as grbl acknowledges lines when they are
+// planned, not when they are executed, the restart point may need to be
+// moved back a few lines when the job was streamed.
+func (a *Actions) Resume(ctx context.Context, line int) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	if a.running == nil {
+		return errors.New("actions: resume: no job started")
+	}
+
+	start := a.LastLine()
+	if line > 0 {
+		start = line - 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	if n := a.running.len(); start >= n {
+		return fmt.Errorf("actions: resume: line out of range (1-%d): %d", n, start+1)
+	}
+
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return err
+	}
+
+	a.Grbl.RLock()
+	state, stateName := a.Grbl.State, a.Grbl.StateName
+	a.Grbl.RUnlock()
+
+	if state != response.StateIdle {
+		return fmt.Errorf("actions: resume: grbl is not idle: %s", stateName)
+	}
+
+	src := a.running.Source()
+	st := a.runningState
+	safeZ := resumeSafeZ
+	foundZ := false
+	for i := 0; i < start; i++ {
+		l, err := src.Next()
+		if err != nil {
+			return err
+		}
+
+		if _, err := st.Process(l); err != nil {
+			return err
+		}
+
+		if st.Known[2] && (!foundZ || st.Position.Z > safeZ) {
+			safeZ = st.Position.Z
+			foundZ = true
+		}
+	}
+
+	if !st.PositionKnown() {
+		return fmt.Errorf("actions: resume: position before line %d is unknown", start+1)
+	}
+
+	pre, err := resumePreamble(&st, safeZ)
+	if err != nil {
+		return err
+	}
+
+	rsrc := &resumeSource{
+		pre: pre.Source(),
+		src: src,
+	}
+
+	// G80 and probing motions are not restored, grbl rejects axis
+	// words after G80 anyway.
+	switch st.Motion {
+	case 0, 1, 2, 3:
+		rsrc.motion = &gcode.Field{
+			Letter: 'G',
+			Value:  st.Motion,
+		}
+	}
+
+	log.Printf("resume: restarting job from line %d", start+1)
+
+	a.progress.start(start)
+	defer a.progress.stop()
+
+	toolChange := a.Grbl.ToolChange
+	a.Grbl.ToolChange = a.handleToolChange
+	defer func() {
+		a.Grbl.ToolChange = toolChange
+	}()
+
+	return a.Grbl.SendSourceWithErrorHandler(ctx, rsrc, func(index int) {
+		if index >= len(pre) {
+			a.progress.ack(start + index - len(pre))
+		}
+	}, a.lineErrorHandler(start, len(pre)))
+}
@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"context"
+	"log"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/settings"
+)
+
+// Settings returns the current settings of grbl.
+func (a *Actions) Settings(ctx context.Context) (map[uint8]float64, error) {
+	if a == nil || a.Grbl == nil {
+		return nil, ErrGrblNotSet
+	}
+
+	if err := a.Grbl.RefreshSettings(ctx); err != nil {
+		return nil, err
+	}
+
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
+	rv := map[uint8]float64{}
+	for k, v := range a.Grbl.Settings {
+		rv[k] = v
+	}
+	return rv, nil
+}
+
+func (a *Actions) SetSetting(ctx context.Context, key uint8, value float64) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	return a.Grbl.SetSetting(ctx, key, value)
+}
+
+// SaveSettings saves the current settings of grbl to a profile file.
+func (a *Actions) SaveSettings(ctx context.Context, fname string) error {
+	s, err := a.Settings(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.Grbl.RLock()
+	version := a.Grbl.Version
+	a.Grbl.RUnlock()
+
+	if err := settings.NewProfile(version, s).Save(fname); err != nil {
+		return err
+	}
+
+	log.Printf("settings: %d settings saved to %s", len(s), fname)
+	return nil
+}
+
+// DiffSettings returns the changes needed to restore the settings saved
+// to a profile file.
+func (a *Actions) DiffSettings(ctx context.Context, fname string) ([]*settings.Change, error) {
+	p, err := settings.LoadProfile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := a.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings.Diff(s, p.Settings), nil
+}
+
+// RestoreSettings writes the settings saved to a profile file that differ
+// from the current ones to grbl.
+func (a *Actions) RestoreSettings(ctx context.Context, fname string) error {
+	changes, err := a.DiffSettings(ctx, fname)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		// settings unknown to the profile are kept
+		if c.To == nil {
+			continue
+		}
+
+		if err := a.Grbl.SetSetting(ctx, c.Key, *c.To); err != nil {
+			return err
+		}
+		log.Printf("settings: %s", c)
+	}
+
+	return nil
+}
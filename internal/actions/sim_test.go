@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/sim"
+)
+
+// newSimActions returns actions connected to a simulated grbl.
+func newSimActions(t *testing.T, cfg *sim.Config) (*Actions, *sim.Sim) {
+	t.Helper()
+
+	if cfg == nil {
+		cfg = &sim.Config{}
+	}
+	if cfg.Speedup == 0 {
+		cfg.Speedup = 100
+	}
+
+	s, err := sim.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := grbl.NewGrbl(s)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		g.Close()
+	})
+
+	return &Actions{
+		Grbl:          g,
+		AutoLevelCfg:  autolevel.DefaultConfig(),
+		ToolChangeCfg: DefaultToolChangeConfig(),
+	}, s
+}
+
+// loadJob writes a g-code file and loads it as the current job.
+func loadJob(t *testing.T, ctx context.Context, a *Actions, data string) {
+	t.Helper()
+
+	fname := filepath.Join(t.TempDir(), "job.nc")
+	if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadGCode(ctx, fname); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// waitIdle waits for the machine to finish its motions.
+func waitIdle(t *testing.T, ctx context.Context, a *Actions) {
+	t.Helper()
+
+	for {
+		if err := a.Grbl.RefreshStatus(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		a.Grbl.RLock()
+		state := a.Grbl.State
+		a.Grbl.RUnlock()
+
+		if state == response.StateIdle {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitFor polls cond until it returns true.
+func waitFor(t *testing.T, ctx context.Context, cond func() bool) {
+	t.Helper()
+
+	for !cond() {
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
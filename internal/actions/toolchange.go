@@ -0,0 +1,162 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+const DefaultToolChangeHeight = 20. // mm
+
+// ToolChangeConfig defines where tools are changed and probed when a job
+// reaches a M6 command.
+type ToolChangeConfig struct {
+	// Position is where the tool is changed, in machine coordinates. if
+	// nil, the tool is changed at the current XY position, after moving
+	// to Height.
+	Position *point.Point
+
+	// Height is the work Z position used to change tools if Position is
+	// not defined, in mm.
+	Height float64
+
+	// ProbeX and ProbeY are the work position where Z is probed for the
+	// new tool, in mm.
+	ProbeX float64
+	ProbeY float64
+}
+
+func DefaultToolChangeConfig() ToolChangeConfig {
+	return ToolChangeConfig{
+		Height: DefaultToolChangeHeight,
+	}
+}
+
+type toolChange struct {
+	sync.Mutex
+	tool    float64
+	pending bool
+	done    chan struct{}
+}
+
+// ToolChangePending returns the tool to be inserted, if the running job
+// is waiting for a tool change.
+func (a *Actions) ToolChangePending() (float64, bool) {
+	a.toolChange.Lock()
+	defer a.toolChange.Unlock()
+
+	return a.toolChange.tool, a.toolChange.pending
+}
+
+// ToolChangeContinue notifies the running job that the new tool was
+// inserted.
+func (a *Actions) ToolChangeContinue() error {
+	a.toolChange.Lock()
+	defer a.toolChange.Unlock()
+
+	if !a.toolChange.pending {
+		return errors.New("actions: tool-change: no tool change pending")
+	}
+
+	a.toolChange.pending = false
+	close(a.toolChange.done)
+	return nil
+}
+
+func (a *Actions) waitToolChange(ctx context.Context, tool float64) error {
+	a.toolChange.Lock()
+	a.toolChange.tool = tool
+	a.toolChange.pending = true
+	a.toolChange.done = make(chan struct{})
+	done := a.toolChange.done
+	a.toolChange.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.toolChange.Lock()
+		a.toolChange.pending = false
+		a.toolChange.Unlock()
+		return ctx.Err()
+	}
+}
+
+// handleToolChange pauses the job to change the tool, re-probes Z with the
+// new tool and returns to the position and state where the job stopped.
+// the XY zero and the autolevel map are preserved.
+func (a *Actions) handleToolChange(ctx context.Context, l gcode.Line) error {
+	// wait for the previous motions to complete
+	if err := a.Grbl.SendGCodeInline(ctx, "G04 P0.001"); err != nil {
+		return err
+	}
+
+	// jobs may change tools before moving all the axes
+	if err := a.Grbl.RefreshStatus(ctx); err != nil {
+		return err
+	}
+
+	a.Grbl.RLock()
+	known := a.Grbl.GCodeState != nil
+	a.Grbl.RUnlock()
+
+	if !known {
+		return errors.New("actions: tool-change: gcode state unknown")
+	}
+
+	st := a.jobState()
+	if !st.PositionKnown() {
+		return errors.New("actions: tool-change: position unknown")
+	}
+
+	tool := st.Tool
+	if t := l.Get('T'); t != nil {
+		tool = t.Value
+	}
+
+	// the surface below the probe position may not be at the work zero
+	// when autolevel is enabled.
+	z := 0.
+	if a.ProbeSpline != nil {
+		var err error
+		z, err = a.ProbeSpline.At(a.ToolChangeCfg.ProbeX, a.ToolChangeCfg.ProbeY)
+		if err != nil {
+			return fmt.Errorf("actions: tool-change: probe position out of the autolevel area: %w", err)
+		}
+	}
+
+	move := fmt.Sprintf("G21 G90\nG00 Z%.3f\n", a.ToolChangeCfg.Height)
+	if p := a.ToolChangeCfg.Position; p != nil {
+		move = fmt.Sprintf("G21 G90\nG53 G00 Z%.3f\nG53 G00 X%.3f Y%.3f\n", p.Z, p.X, p.Y)
+	}
+	if err := a.Grbl.SendGCodeInline(ctx, "M05\nM09\n"+move+"G04 P0.001"); err != nil {
+		return err
+	}
+
+	log.Printf("tool change: insert tool T%.0f and press 'c' to continue", tool)
+	if err := a.waitToolChange(ctx, tool); err != nil {
+		return err
+	}
+
+	// probe from the tool change height
+	if err := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf("G21 G90\nG00 X%.3f Y%.3f", a.ToolChangeCfg.ProbeX, a.ToolChangeCfg.ProbeY)); err != nil {
+		return err
+	}
+	if err := a.probeZ(ctx, z); err != nil {
+		return err
+	}
+
+	pre, err := resumePreamble(&st, math.Max(resumeSafeZ, st.Position.Z))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("tool change: resuming job with tool T%.0f", tool)
+	return a.Grbl.SendJob(ctx, pre)
+}
@@ -0,0 +1,71 @@
+package actions
+
+import (
+	"testing"
+)
+
+// tool changes before any XY motion, like the drill files generated by
+// flatcam.
+const drillJob = `G21
+G90
+G94
+G01 F60.00
+G00 Z15.0000
+T1
+M5
+M6
+(MSG, Change to Tool Dia = 0.8000)
+M0
+G00 Z15.0000
+M03
+G01 F60.00
+G00 X10.0000 Y10.0000
+G01 Z-1.7000
+G01 Z0
+G00 Z15.0000
+M05
+G00 Z15.00
+G00 X0 Y0
+M2
+`
+
+func TestToolChangeUnknownPosition(t *testing.T) {
+	ctx := testContext(t)
+	a, _ := newSimActions(t, nil)
+	loadJob(t, ctx, a, drillJob)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Start(ctx)
+	}()
+
+	waitFor(t, ctx, func() bool {
+		select {
+		case err := <-done:
+			t.Fatalf("job finished before the tool change: %v", err)
+		default:
+		}
+		_, pending := a.ToolChangePending()
+		return pending
+	})
+
+	if tool, _ := a.ToolChangePending(); tool != 1 {
+		t.Errorf("unexpected tool: T%.0f", tool)
+	}
+	if err := a.ToolChangeContinue(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, ctx, a)
+
+	a.Grbl.RLock()
+	wpos := a.Grbl.WPos.Copy()
+	a.Grbl.RUnlock()
+
+	if wpos.X != 0 || wpos.Y != 0 || wpos.Z != 15 {
+		t.Errorf("unexpected position after job: %s", wpos)
+	}
+}
@@ -0,0 +1,224 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// offsetsVersion is the version of the work coordinate systems file format.
+const offsetsVersion = 1
+
+// Offsets are the offsets of the work coordinate systems, in mm.
+type Offsets struct {
+	Current           int // 54-59
+	CoordinateSystems [6]*point.Point
+	G92               *point.Point
+	TLO               float64
+}
+
+type offsetsFile struct {
+	Version           int                     `json:"version"`
+	Created           time.Time               `json:"created"`
+	CoordinateSystems map[string]*point.Point `json:"coordinate_systems"`
+}
+
+// ParseWCS parses the name of a work coordinate system (G54-G59), returning
+// its number (54-59).
+func ParseWCS(name string) (int, error) {
+	n := strings.TrimPrefix(strings.ToUpper(name), "G")
+	wcs, err := strconv.Atoi(n)
+	if err != nil || wcs < 54 || wcs > 59 {
+		return 0, fmt.Errorf("actions: invalid work coordinate system: %s", name)
+	}
+	return wcs, nil
+}
+
+// Offsets returns the offsets of all the work coordinate systems, and the
+// current one.
+func (a *Actions) Offsets(ctx context.Context) (*Offsets, error) {
+	if a == nil || a.Grbl == nil {
+		return nil, ErrGrblNotSet
+	}
+
+	if err := a.Grbl.RefreshParameters(ctx); err != nil {
+		return nil, err
+	}
+
+	a.Grbl.RLock()
+	defer a.Grbl.RUnlock()
+
+	rv := &Offsets{
+		Current: 54,
+	}
+	if a.Grbl.GCodeState != nil {
+		rv.Current = int(a.Grbl.GCodeState.WCS)
+	}
+	for i, p := range a.Grbl.CoordinateSystems {
+		if p == nil {
+			return nil, errors.New("actions: work coordinate system offsets unknown")
+		}
+		rv.CoordinateSystems[i] = p.Copy()
+	}
+	if a.Grbl.G92 != nil {
+		rv.G92 = a.Grbl.G92.Copy()
+	}
+	if a.Grbl.TLO != nil {
+		rv.TLO = *a.Grbl.TLO
+	}
+	return rv, nil
+}
+
+// SetWCS selects the work coordinate system (54-59) used by the following
+// commands and jobs.
+func (a *Actions) SetWCS(ctx context.Context, wcs int) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	if wcs < 54 || wcs > 59 {
+		return fmt.Errorf("actions: invalid work coordinate system: %d", wcs)
+	}
+
+	if err := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf("G%d", wcs)); err != nil {
+		return err
+	}
+
+	log.Printf("wcs: G%d selected", wcs)
+	return nil
+}
+
+// SetZero sets the current position as the zero of the given axes of a work
+// coordinate system (54-59, or 0 for the current one).
+func (a *Actions) SetZero(ctx context.Context, wcs int, axes string) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	p := 0
+	if wcs != 0 {
+		if wcs < 54 || wcs > 59 {
+			return fmt.Errorf("actions: invalid work coordinate system: %d", wcs)
+		}
+		p = wcs - 53
+	}
+
+	if axes == "" {
+		return errors.New("actions: no axes to zero")
+	}
+
+	cmd := fmt.Sprintf("G10 L20 P%d", p)
+	seen := map[rune]bool{}
+	for _, axis := range strings.ToUpper(axes) {
+		if axis < 'X' || axis > 'Z' || seen[axis] {
+			return fmt.Errorf("actions: invalid axes: %s", axes)
+		}
+		seen[axis] = true
+		cmd += fmt.Sprintf(" %c0", axis)
+	}
+
+	return a.Grbl.SendGCodeInline(ctx, cmd)
+}
+
+// SaveOffsets saves the offsets of all the work coordinate systems to a
+// file.
+func (a *Actions) SaveOffsets(ctx context.Context, fname string) error {
+	o, err := a.Offsets(ctx)
+	if err != nil {
+		return err
+	}
+
+	f := &offsetsFile{
+		Version:           offsetsVersion,
+		Created:           time.Now().UTC(),
+		CoordinateSystems: map[string]*point.Point{},
+	}
+	for i, p := range o.CoordinateSystems {
+		f.CoordinateSystems[fmt.Sprintf("G%d", 54+i)] = p
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fname, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+
+	log.Printf("wcs: offsets saved to %s", fname)
+	return nil
+}
+
+// LoadOffsets writes the offsets of the work coordinate systems saved to a
+// file to grbl. work coordinate systems missing from the file are kept.
+func (a *Actions) LoadOffsets(ctx context.Context, fname string) error {
+	if a == nil || a.Grbl == nil {
+		return ErrGrblNotSet
+	}
+
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+
+	f := &offsetsFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return err
+	}
+	if f.Version != offsetsVersion {
+		return fmt.Errorf("actions: unsupported offsets file version: %d", f.Version)
+	}
+
+	a.Grbl.RLock()
+	units := 0.
+	if a.Grbl.GCodeState != nil {
+		units = a.Grbl.GCodeState.Units
+	}
+	a.Grbl.RUnlock()
+
+	if units == 0 {
+		return errors.New("actions: load offsets: gcode state unknown")
+	}
+
+	// validate everything before writing anything
+	cmds := []string{}
+	for wcs := 54; wcs <= 59; wcs++ {
+		name := fmt.Sprintf("G%d", wcs)
+		p, ok := f.CoordinateSystems[name]
+		if !ok {
+			continue
+		}
+		if p == nil {
+			return fmt.Errorf("actions: invalid offsets for %s", name)
+		}
+		cmds = append(cmds, fmt.Sprintf("G10 L2 P%d X%.3f Y%.3f Z%.3f", wcs-53, p.X, p.Y, p.Z))
+	}
+	for name := range f.CoordinateSystems {
+		if _, err := ParseWCS(name); err != nil {
+			return err
+		}
+	}
+
+	err = a.Grbl.SendGCodeInline(ctx, "G21\n"+strings.Join(cmds, "\n"))
+
+	// offsets are stored in mm, restore the units of grbl afterwards
+	if units != 21 {
+		if uerr := a.Grbl.SendGCodeInline(ctx, fmt.Sprintf("G%.0f", units)); err == nil {
+			err = uerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("wcs: %d offsets loaded from %s", len(cmds), fname)
+	return nil
+}
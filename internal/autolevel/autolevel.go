@@ -2,68 +2,288 @@ package autolevel
 
 import (
 	"errors"
+	"math"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
-	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/interp2d"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
 )
 
-func AutoLevel(gc gcode.Job, spline *interp2d.Spline, gcs grbl.GCodeStates) (gcode.Job, error) {
-	rv := []gcode.Line{}
+const (
+	DefaultMaxSegmentLength = 2.
+	DefaultSafeHeight       = 1.
+)
+
+// Config defines how the moves are adjusted by AutoLevel.
+type Config struct {
+	// ArcTolerance is the maximum distance between G2/G3 arcs and the G1
+	// segments that replace them, in mm.
+	ArcTolerance float64
+
+	// MaxSegmentLength is the maximum XY length of the segments that
+	// replace G1 moves, in mm.
+	MaxSegmentLength float64
+
+	// SafeHeight is the Z position, in mm, above which G0 moves are not
+	// adjusted.
+	SafeHeight float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ArcTolerance:     gcode.DefaultArcTolerance,
+		MaxSegmentLength: DefaultMaxSegmentLength,
+		SafeHeight:       DefaultSafeHeight,
+	}
+}
 
-	var (
-		state  = &point.Point{}
-		foundX bool
-		foundY bool
-		foundZ bool
-	)
+type leveler struct {
+	src    gcode.Source
+	spline *interp2d.Spline
+	st     *gcode.State
+	cfg    Config
 
-	for _, l := range gc {
-		if gcs.Distance == "G91" {
-			return nil, errors.New("autolevel: can't autolevel incremental positions") // FIXME
+	// lines generated for the last line read from src, not returned yet.
+	pending []gcode.Line
+
+	// last position sent to grbl, absolute and in mm. it differs from the
+	// programmed position by the leveling adjustments.
+	emitted *point.Point
+}
+
+func (lv *leveler) level(p *point.Point) (*point.Point, error) {
+	z := p.Z
+	if lv.spline != nil {
+		dz, err := lv.spline.At(p.X, p.Y)
+		if err != nil {
+			return nil, err
 		}
+		z += dz
+	}
+
+	return &point.Point{
+		X: p.X,
+		Y: p.Y,
+		Z: z,
+	}, nil
+}
+
+// isMotionWord returns true for the words consumed by a motion, that must
+// not be copied to the segments that replace it.
+func isMotionWord(f gcode.Field) bool {
+	switch f.Letter {
+	case 'X', 'Y', 'Z', 'I', 'J', 'K', 'R':
+		return true
+	case 'G':
+		return f.Value == 0 || f.Value == 1 || f.Value == 2 || f.Value == 3
+	}
+	return false
+}
 
-		if !l.HasPosition() {
-			gcs.ProcessLine(l)
-			rv = append(rv, l)
+// setsPosition returns true if the axis words of a line set the current
+// position in the current coordinate system (G92 and G10 L20), instead of
+// moving to it.
+func setsPosition(l gcode.Line, st *gcode.State) bool {
+	for _, f := range l {
+		if f.Letter != 'G' {
 			continue
 		}
-
-		if x := l.Get('X'); x != nil {
-			foundX = true
-			state.X = gcs.ToMM(x.Value)
+		switch f.Value {
+		case 92:
+			return true
+		case 10:
+			lw, pw := l.Get('L'), l.Get('P')
+			return lw != nil && lw.Value == 20 && pw != nil && (pw.Value == 0 || pw.Value+53 == st.WCS)
 		}
+	}
+	return false
+}
 
-		if y := l.Get('Y'); y != nil {
-			foundY = true
-			state.Y = gcs.ToMM(y.Value)
-		}
+// emit returns the lines that move to the given points, absolute and in
+// mm, using the program's current units and distance mode. if split is
+// true, the motion of the line is replaced by G1 segments. the other words
+// of the line are kept in the first one.
+func (lv *leveler) emit(l gcode.Line, pts []*point.Point, level bool, split bool) ([]gcode.Line, error) {
+	rv := []gcode.Line{}
 
-		if z := l.Get('Z'); z != nil {
-			foundZ = true
-			state.Z = gcs.ToMM(z.Value)
+	for i, p := range pts {
+		if level {
+			var err error
+			p, err = lv.level(p)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		if foundX && foundY && foundZ {
-			z := state.Z
-			if spline != nil {
-				dz, err := spline.At(state.X, state.Y)
-				if err != nil {
-					return nil, err
+		seg := gcode.Line{}
+		if split {
+			seg = append(seg, gcode.Field{
+				Letter: 'G',
+				Value:  1,
+			})
+		}
+		if i == 0 {
+			for _, f := range l {
+				if f.Letter == 'X' || f.Letter == 'Y' || f.Letter == 'Z' || (split && isMotionWord(f)) {
+					continue
 				}
-				z += dz
+				seg = append(seg, f)
 			}
-			l.SetPosition(&point.Point{
-				X: state.X,
-				Y: state.Y,
-				Z: z,
+		}
+
+		out := p
+		if lv.st.Distance == 91 {
+			out = p.Sub(lv.emitted)
+		}
+		out = &point.Point{
+			X: round(lv.st.FromMM(out.X)),
+			Y: round(lv.st.FromMM(out.Y)),
+			Z: round(lv.st.FromMM(out.Z)),
+		}
+		seg.SetPosition(out)
+
+		// track the rounded position, so incremental moves don't
+		// accumulate rounding errors.
+		if lv.st.Distance == 91 {
+			lv.emitted = lv.emitted.Add(&point.Point{
+				X: lv.st.ToMM(out.X),
+				Y: lv.st.ToMM(out.Y),
+				Z: lv.st.ToMM(out.Z),
 			})
+		} else {
+			lv.emitted = p.Copy()
 		}
 
-		gcs.ProcessLine(l)
-		rv = append(rv, l)
+		rv = append(rv, seg)
 	}
 
 	return rv, nil
 }
+
+// round rounds a value to the precision used when formatting fields.
+func round(v float64) float64 {
+	// adding zero also gets rid of negative zeros
+	return math.Round(v*1e4)/1e4 + 0
+}
+
+// split returns the end points of the segments of a linear move that are
+// at most maxLength long in the XY plane, not including the start point.
+func split(start *point.Point, end *point.Point, maxLength float64) []*point.Point {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxSegmentLength
+	}
+
+	n := int(math.Ceil(math.Hypot(end.X-start.X, end.Y-start.Y) / maxLength))
+	if n < 1 {
+		n = 1
+	}
+
+	rv := make([]*point.Point, 0, n)
+	for k := 1; k < n; k++ {
+		t := float64(k) / float64(n)
+		rv = append(rv, &point.Point{
+			X: start.X + (end.X-start.X)*t,
+			Y: start.Y + (end.Y-start.Y)*t,
+			Z: start.Z + (end.Z-start.Z)*t,
+		})
+	}
+
+	return append(rv, end.Copy())
+}
+
+// NewSource returns a Source that adjusts the Z position of the moves read
+// from src using the height map interpolated by spline, starting from the
+// given modal state. the adjusted moves are emitted in the program's own
+// units and distance mode.
+func NewSource(src gcode.Source, spline *interp2d.Spline, st gcode.State, cfg Config) gcode.Source {
+	return &leveler{
+		src:     src,
+		spline:  spline,
+		st:      &st,
+		cfg:     cfg,
+		emitted: st.Position.Copy(),
+	}
+}
+
+func (lv *leveler) Next() (gcode.Line, error) {
+	for len(lv.pending) == 0 {
+		l, err := lv.src.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		lv.pending, err = lv.process(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rv := lv.pending[0]
+	lv.pending = lv.pending[1:]
+	return rv, nil
+}
+
+func (lv *leveler) process(l gcode.Line) ([]gcode.Line, error) {
+	st := lv.st
+	prev := st.Position.Copy()
+
+	m, err := st.Process(l)
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil || m.Mode == 4 || !m.EndKnown {
+		// lines that are not motions, or motions to positions that are
+		// not fully known, can't be adjusted.
+		if l.HasPosition() {
+			correction := lv.emitted.Sub(prev)
+			lv.emitted = st.Position.Copy()
+
+			// coordinate system changes don't move the machine, that
+			// is still off the programmed position by the leveling
+			// correction of the last move, but for the axes whose
+			// position is set by G92 or G10 L20.
+			if m == nil {
+				if setsPosition(l, st) {
+					if l.Get('X') != nil {
+						correction.X = 0
+					}
+					if l.Get('Y') != nil {
+						correction.Y = 0
+					}
+					if l.Get('Z') != nil {
+						correction.Z = 0
+					}
+				}
+				lv.emitted = lv.emitted.Add(correction)
+			}
+		}
+		return []gcode.Line{l}, nil
+	}
+
+	switch {
+	case m.Mode == 2 || m.Mode == 3:
+		if m.Arc == nil {
+			return nil, errors.New("autolevel: arc with undefined start position")
+		}
+		return lv.emit(l, m.Arc.Points(lv.cfg.ArcTolerance), true, true)
+
+	case m.Mode == 0 && m.End.Z >= lv.cfg.SafeHeight:
+		// rapid moves above the safe height can't touch the board
+		if st.Distance == 91 {
+			return lv.emit(l, []*point.Point{m.End}, false, false)
+		}
+		lv.emitted = m.End.Copy()
+		return []gcode.Line{l}, nil
+
+	case m.Mode == 1 && m.StartKnown:
+		return lv.emit(l, split(m.Start, m.End, lv.cfg.MaxSegmentLength), true, true)
+	}
+
+	return lv.emit(l, []*point.Point{m.End}, true, false)
+}
+
+// AutoLevel adjusts the moves of a job like NewSource.
+func AutoLevel(gc gcode.Job, spline *interp2d.Spline, st gcode.State, cfg Config) (gcode.Job, error) {
+	return gcode.ReadJob(NewSource(gc.Source(), spline, st, cfg))
+}
@@ -0,0 +1,144 @@
+package autolevel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/interp2d"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+func flatSpline(t *testing.T, z float64) *interp2d.Spline {
+	t.Helper()
+
+	pts := [][]*point.Point{}
+	for _, y := range []float64{-50, 50} {
+		row := []*point.Point{}
+		for _, x := range []float64{-50, 50} {
+			row = append(row, &point.Point{X: x, Y: y, Z: z})
+		}
+		pts = append(pts, row)
+	}
+
+	rv, err := interp2d.NewSpline(pts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rv.Close)
+	return rv
+}
+
+// finalPosition returns the position reached by a job, in mm.
+func finalPosition(t *testing.T, j gcode.Job) *point.Point {
+	t.Helper()
+
+	st := gcode.NewState()
+	for _, l := range j {
+		if _, err := st.Process(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st.Position.Copy()
+}
+
+func TestLevel(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		want *point.Point
+	}{
+		{
+			name: "absolute",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG1 X5 F100\nG1 Z-0.1\n",
+			want: &point.Point{X: 5, Y: 0, Z: 0},
+		},
+		{
+			name: "relative",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG91\nG1 X5 F100\nG1 Y5 Z-0.1\n",
+			want: &point.Point{X: 5, Y: 5, Z: 0},
+		},
+		{
+			name: "relative inches",
+			data: "G20 G90\nG0 X0 Y0 Z0\nG91\nG1 X0.5 F10\nG1 Y0.5\n",
+			want: &point.Point{X: 12.7, Y: 12.7, Z: 0.1},
+		},
+		{
+			// the machine doesn't move with G92, the correction of the
+			// last move must be kept for the axes it doesn't set.
+			name: "relative after coordinate change",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG91\nG1 X5 F100\nG92 X0 Y0\nG1 X5\nG1 Y5\n",
+			want: &point.Point{X: 5, Y: 5, Z: 0.1},
+		},
+		{
+			name: "relative after coordinate change of all axes",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG91\nG1 X5 F100\nG92 X0 Y0 Z0\nG1 X5\nG1 Y5\n",
+			want: &point.Point{X: 5, Y: 5, Z: 0.1},
+		},
+		{
+			name: "relative after offset change",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG91\nG1 X5 F100\nG10 L20 P1 X0 Y0\nG1 X5\nG1 Y5\n",
+			want: &point.Point{X: 5, Y: 5, Z: 0.1},
+		},
+		{
+			name: "rapid above safe height",
+			data: "G21 G90\nG0 X0 Y0 Z0\nG0 Z5\nG0 X10\n",
+			want: &point.Point{X: 10, Y: 0, Z: 5},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := gcode.NewJobFromData(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rv, err := AutoLevel(j, flatSpline(t, 0.1), *gcode.NewState(), DefaultConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := finalPosition(t, rv)
+			if math.Abs(got.X-tt.want.X) > 1e-3 || math.Abs(got.Y-tt.want.Y) > 1e-3 || math.Abs(got.Z-tt.want.Z) > 1e-3 {
+				t.Errorf("got %s, want %s\n%s", got, tt.want, rv)
+			}
+		})
+	}
+}
+
+func TestLevelSplit(t *testing.T) {
+	j, err := gcode.NewJobFromData("G21 G90\nG0 X0 Y0 Z0\nG1 X10 F100\nG2 X20 Y0 I5 J0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	rv, err := AutoLevel(j, flatSpline(t, -0.2), *gcode.NewState(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := gcode.NewState()
+	for i, l := range rv {
+		m, err := st.Process(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 || m == nil {
+			continue
+		}
+
+		if m.Mode != 1 {
+			t.Errorf("line %d: moves must be replaced by G1 segments: %s", i+1, l)
+		}
+		if d := math.Hypot(m.End.X-m.Start.X, m.End.Y-m.Start.Y); d > cfg.MaxSegmentLength+1e-3 {
+			t.Errorf("line %d: segment too long: %.3f: %s", i+1, d, l)
+		}
+		if math.Abs(m.End.Z+0.2) > 1e-3 {
+			t.Errorf("line %d: segment not leveled: %s", i+1, l)
+		}
+	}
+
+	if got := st.Position; math.Abs(got.X-20) > 1e-3 || math.Abs(got.Y) > 1e-3 {
+		t.Errorf("unexpected end position: %s", &got)
+	}
+}
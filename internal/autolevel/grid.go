@@ -0,0 +1,145 @@
+package autolevel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	DefaultGridSpacing = 10.
+	DefaultGridMargin  = 0.2
+
+	// the interpolation needs at least 2 points per axis
+	MinGridPoints = 2
+)
+
+// GridConfig defines how the probe points are distributed over the job.
+// the number of points of each axis is computed from Spacing, unless it is
+// explicitly defined by NX or NY.
+type GridConfig struct {
+	Spacing float64
+	NX      int
+	NY      int
+	Margin  float64
+}
+
+// Grid is a rectangular grid of probe points, in work coordinates.
+type Grid struct {
+	MinX  float64
+	MinY  float64
+	MaxX  float64
+	MaxY  float64
+	NX    int
+	NY    int
+	StepX float64
+	StepY float64
+}
+
+func gridPoints(dist float64, spacing float64, n int) (int, error) {
+	if n == 0 {
+		n = int(math.Ceil(dist/spacing)) + 1
+		if n < MinGridPoints {
+			n = MinGridPoints
+		}
+	}
+
+	if n < MinGridPoints {
+		return 0, fmt.Errorf("autolevel: grid must have at least %d points per axis: %d", MinGridPoints, n)
+	}
+
+	return n, nil
+}
+
+// NewGrid creates a grid covering the given bounding box, plus margins.
+func NewGrid(minx float64, miny float64, maxx float64, maxy float64, cfg GridConfig) (*Grid, error) {
+	if cfg.Spacing == 0 {
+		cfg.Spacing = DefaultGridSpacing
+	}
+
+	if cfg.Spacing < 0 {
+		return nil, fmt.Errorf("autolevel: invalid grid spacing: %.3f", cfg.Spacing)
+	}
+
+	if cfg.Margin < 0 {
+		return nil, fmt.Errorf("autolevel: invalid grid margin: %.3f", cfg.Margin)
+	}
+
+	if cfg.NX < 0 || cfg.NY < 0 {
+		return nil, fmt.Errorf("autolevel: invalid number of grid points: %dx%d", cfg.NX, cfg.NY)
+	}
+
+	if minx > maxx || miny > maxy {
+		return nil, errors.New("autolevel: invalid bounding box")
+	}
+
+	rv := &Grid{
+		MinX: minx - cfg.Margin,
+		MinY: miny - cfg.Margin,
+		MaxX: maxx + cfg.Margin,
+		MaxY: maxy + cfg.Margin,
+	}
+
+	distx := rv.MaxX - rv.MinX
+	disty := rv.MaxY - rv.MinY
+	if distx <= 0 || disty <= 0 {
+		return nil, fmt.Errorf("autolevel: job area too small for a grid: %.3f x %.3f mm, try adding a margin", distx, disty)
+	}
+
+	var err error
+	rv.NX, err = gridPoints(distx, cfg.Spacing, cfg.NX)
+	if err != nil {
+		return nil, err
+	}
+	rv.NY, err = gridPoints(disty, cfg.Spacing, cfg.NY)
+	if err != nil {
+		return nil, err
+	}
+
+	rv.StepX = distx / float64(rv.NX-1)
+	rv.StepY = disty / float64(rv.NY-1)
+
+	return rv, nil
+}
+
+// Point returns the position of the point at column i and row j.
+func (g *Grid) Point(i int, j int) (float64, float64) {
+	x := g.MinX + float64(i)*g.StepX
+	y := g.MinY + float64(j)*g.StepY
+
+	// avoid rounding errors at the edges, the grid must cover the whole job
+	if i == g.NX-1 {
+		x = g.MaxX
+	}
+	if j == g.NY-1 {
+		y = g.MaxY
+	}
+
+	return x, y
+}
+
+// Preview returns a human-readable description of the grid, with the
+// coordinates of its columns and rows.
+func (g *Grid) Preview() string {
+	rv := &strings.Builder{}
+
+	fmt.Fprintf(rv, "grid: %dx%d points (%d probes), step: %.3f x %.3f mm\n", g.NX, g.NY, g.NX*g.NY, g.StepX, g.StepY)
+	fmt.Fprintf(rv, "area: X=%.3f,Y=%.3f -> X=%.3f,Y=%.3f\n", g.MinX, g.MinY, g.MaxX, g.MaxY)
+
+	fmt.Fprint(rv, "X:")
+	for i := 0; i < g.NX; i++ {
+		x, _ := g.Point(i, 0)
+		fmt.Fprintf(rv, " %.3f", x)
+	}
+	fmt.Fprintln(rv)
+
+	fmt.Fprint(rv, "Y:")
+	for j := 0; j < g.NY; j++ {
+		_, y := g.Point(0, j)
+		fmt.Fprintf(rv, " %.3f", y)
+	}
+	fmt.Fprintln(rv)
+
+	return rv.String()
+}
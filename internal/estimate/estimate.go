@@ -0,0 +1,393 @@
+package estimate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+const (
+	DefaultJunctionDeviation = 0.01  // mm, grbl's $11 default
+	DefaultArcTolerance      = 0.002 // mm, grbl's $12 default
+
+	// size of grbl's planner buffer, that limits how far ahead it can
+	// plan the speed of the motions.
+	plannerBlocks = 16
+)
+
+// Machine is the motion limits of the machine, from grbl settings.
+type Machine struct {
+	MaxRate           [3]float64 // mm/min, $110-$112
+	Acceleration      [3]float64 // mm/s^2, $120-$122
+	JunctionDeviation float64    // mm, $11
+	ArcTolerance      float64    // mm, $12
+}
+
+func NewMachine(settings map[uint8]float64) (*Machine, error) {
+	rv := &Machine{
+		JunctionDeviation: DefaultJunctionDeviation,
+		ArcTolerance:      DefaultArcTolerance,
+	}
+
+	for i := 0; i < 3; i++ {
+		for _, s := range []struct {
+			key uint8
+			v   *float64
+		}{
+			{uint8(110 + i), &rv.MaxRate[i]},
+			{uint8(120 + i), &rv.Acceleration[i]},
+		} {
+			v, ok := settings[s.key]
+			if !ok {
+				return nil, fmt.Errorf("estimate: setting not found: $%d", s.key)
+			}
+			if v <= 0 {
+				return nil, fmt.Errorf("estimate: invalid setting: $%d=%.3f", s.key, v)
+			}
+			*s.v = v
+		}
+	}
+
+	if v, ok := settings[11]; ok && v > 0 {
+		rv.JunctionDeviation = v
+	}
+	if v, ok := settings[12]; ok && v > 0 {
+		rv.ArcTolerance = v
+	}
+
+	return rv, nil
+}
+
+// Stats is the time and distance estimated for a set of motions.
+// distances are in mm.
+type Stats struct {
+	Time            time.Duration
+	CuttingTime     time.Duration
+	TravelTime      time.Duration
+	DwellTime       time.Duration
+	CuttingDistance float64
+	TravelDistance  float64
+}
+
+type ToolStats struct {
+	Tool float64
+	Stats
+}
+
+// Estimate is the time estimated for a job, with a breakdown per tool, in
+// the order the tools are used.
+type Estimate struct {
+	Stats
+	Tools []*ToolStats
+	Lines int
+}
+
+func (e *Estimate) tool(t float64) *ToolStats {
+	for _, ts := range e.Tools {
+		if ts.Tool == t {
+			return ts
+		}
+	}
+
+	rv := &ToolStats{Tool: t}
+	e.Tools = append(e.Tools, rv)
+	return rv
+}
+
+func (s *Stats) addMotion(d time.Duration, distance float64, cutting bool) {
+	s.Time += d
+	if cutting {
+		s.CuttingTime += d
+		s.CuttingDistance += distance
+	} else {
+		s.TravelTime += d
+		s.TravelDistance += distance
+	}
+}
+
+func (s *Stats) addDwell(d time.Duration) {
+	s.Time += d
+	s.DwellTime += d
+}
+
+// block is a linear motion, as stored by grbl's planner. speeds are in
+// mm/s.
+type block struct {
+	unit     [3]float64
+	length   float64
+	nominal  float64
+	accel    float64
+	maxEntry float64
+	entry    float64
+	cutting  bool
+	tool     *ToolStats
+}
+
+// limit returns the maximum value along the direction of unit, given the
+// per axis limits, like grbl's limit_value_by_axis_maximum().
+func limit(max [3]float64, unit [3]float64) float64 {
+	rv := math.Inf(1)
+	for i := range unit {
+		if unit[i] != 0 {
+			rv = math.Min(rv, math.Abs(max[i]/unit[i]))
+		}
+	}
+	return rv
+}
+
+// syncs returns true for the lines that make grbl wait for the planned
+// motions to complete before executing them.
+func syncs(l gcode.Line) bool {
+	for _, f := range l {
+		switch f.Letter {
+		case 'G':
+			switch f.Value {
+			case 4, 10, 28, 30, 38.2, 38.3, 38.4, 38.5:
+				return true
+			}
+		case 'M':
+			switch f.Value {
+			case 0, 1, 2, 30, 3, 4, 5, 7, 8, 9:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// changesTool returns true for lines with a M6 command, that loads the
+// tool selected by the last T word.
+func changesTool(l gcode.Line) bool {
+	for _, f := range l {
+		if f.Letter == 'M' && f.Value == 6 {
+			return true
+		}
+	}
+	return false
+}
+
+type estimator struct {
+	m      *Machine
+	rv     *Estimate
+	blocks []*block
+}
+
+// add appends a linear motion to the planner, with the given feed rate
+// in mm/min. zero-length motions are dropped, like in grbl.
+func (e *estimator) add(start *point.Point, end *point.Point, feed float64, rapid bool, tool *ToolStats) {
+	d := end.Sub(start)
+	length := math.Sqrt(d.X*d.X + d.Y*d.Y + d.Z*d.Z)
+	if length < 1e-6 {
+		return
+	}
+
+	b := &block{
+		unit:    [3]float64{d.X / length, d.Y / length, d.Z / length},
+		length:  length,
+		cutting: !rapid,
+		tool:    tool,
+	}
+
+	maxRate := limit(e.m.MaxRate, b.unit)
+	if rapid || feed > maxRate {
+		feed = maxRate
+	}
+	b.nominal = feed / 60
+	b.accel = limit(e.m.Acceleration, b.unit)
+
+	// same as grbl's plan_buffer_line()
+	b.maxEntry = 0
+	if len(e.blocks) > 0 {
+		prev := e.blocks[len(e.blocks)-1]
+
+		cos := -(prev.unit[0]*b.unit[0] + prev.unit[1]*b.unit[1] + prev.unit[2]*b.unit[2])
+		junction := math.Inf(1)
+		if cos > 0.999999 {
+			junction = 0
+		} else if cos >= -0.999999 {
+			ju := [3]float64{}
+			jl := 0.
+			for i := range ju {
+				ju[i] = b.unit[i] - prev.unit[i]
+				jl += ju[i] * ju[i]
+			}
+			jl = math.Sqrt(jl)
+			for i := range ju {
+				ju[i] /= jl
+			}
+
+			accel := limit(e.m.Acceleration, ju)
+			sin := math.Sqrt(0.5 * (1 - cos))
+			junction = math.Sqrt(accel * e.m.JunctionDeviation * sin / (1 - sin))
+		}
+
+		b.maxEntry = math.Min(junction, math.Min(prev.nominal, b.nominal))
+	}
+
+	e.blocks = append(e.blocks, b)
+	if len(e.blocks) > plannerBlocks {
+		e.plan()
+		e.execute()
+	}
+}
+
+// plan computes the entry speeds of the blocks in the planner, assuming
+// that the last one ends stopped.
+func (e *estimator) plan() {
+	n := len(e.blocks)
+
+	// backward pass: blocks must be able to decelerate to the entry
+	// speed of the next one.
+	exit := 0.
+	for i := n - 1; i >= 0; i-- {
+		b := e.blocks[i]
+		b.entry = math.Min(b.maxEntry, math.Sqrt(exit*exit+2*b.accel*b.length))
+		exit = b.entry
+	}
+
+	// forward pass: blocks must be able to accelerate to the entry speed
+	// of the next one.
+	for i := 0; i < n-1; i++ {
+		b := e.blocks[i]
+		next := e.blocks[i+1]
+		next.entry = math.Min(next.entry, math.Sqrt(b.entry*b.entry+2*b.accel*b.length))
+	}
+}
+
+// execute removes the first block from the planner and adds its time to
+// the estimate. the entry speed of the next block can't change anymore.
+func (e *estimator) execute() {
+	b := e.blocks[0]
+	e.blocks = e.blocks[1:]
+
+	exit := 0.
+	if len(e.blocks) > 0 {
+		exit = e.blocks[0].entry
+		e.blocks[0].maxEntry = exit
+	}
+
+	d := time.Duration(b.time(exit) * float64(time.Second))
+	e.rv.addMotion(d, b.length, b.cutting)
+	b.tool.addMotion(d, b.length, b.cutting)
+}
+
+// flush executes all the blocks in the planner, until the machine stops.
+func (e *estimator) flush() {
+	e.plan()
+	for len(e.blocks) > 0 {
+		e.execute()
+	}
+}
+
+// time returns the duration of a block in seconds, using a trapezoidal
+// velocity profile.
+func (b *block) time(exit float64) float64 {
+	accel := (b.nominal*b.nominal - b.entry*b.entry) / (2 * b.accel)
+	decel := (b.nominal*b.nominal - exit*exit) / (2 * b.accel)
+
+	if accel+decel <= b.length {
+		return (b.nominal-b.entry)/b.accel + (b.nominal-exit)/b.accel + (b.length-accel-decel)/b.nominal
+	}
+
+	// the nominal speed is never reached
+	peak := math.Sqrt((2*b.accel*b.length + b.entry*b.entry + exit*exit) / 2)
+	return (peak-b.entry)/b.accel + (peak-exit)/b.accel
+}
+
+// NewEstimate estimates the time needed by grbl to run the lines read from
+// src, starting from the given modal state. positions of the axes that are
+// not known are assumed to be the last ones known, or zero. overrides,
+// homing and G28/G30 motions are not accounted.
+func NewEstimate(src gcode.Source, st gcode.State, m *Machine) (*Estimate, error) {
+	if m == nil {
+		return nil, errors.New("estimate: machine not defined")
+	}
+
+	rv := &Estimate{}
+	e := &estimator{
+		m:  m,
+		rv: rv,
+	}
+
+	// T only selects the next tool, the tool in use changes with M6
+	active := st.Tool
+
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rv.Lines++
+
+		sync := syncs(l)
+		if sync {
+			e.flush()
+		}
+
+		mo, err := st.Process(l)
+		if err != nil {
+			return nil, fmt.Errorf("estimate: line %d: %w", rv.Lines, err)
+		}
+
+		if changesTool(l) {
+			active = st.Tool
+		}
+		tool := rv.tool(active)
+
+		switch {
+		case mo == nil:
+
+		case mo.Mode == 4:
+			d := time.Duration(mo.Dwell * float64(time.Second))
+			rv.addDwell(d)
+			tool.addDwell(d)
+
+		default:
+			rapid := mo.Mode == 0
+			if !rapid && mo.Feed <= 0 {
+				return nil, fmt.Errorf("estimate: line %d: undefined feed rate", rv.Lines)
+			}
+
+			pts := []*point.Point{mo.End}
+			if mo.Arc != nil {
+				pts = mo.Arc.Points(m.ArcTolerance)
+			}
+
+			feed := mo.Feed
+			if st.FeedMode == 93 {
+				// inverse time: the whole motion takes 1/F minutes
+				feed = mo.Length() * mo.Feed
+			}
+
+			start := mo.Start
+			for _, p := range pts {
+				e.add(start, p, feed, rapid, tool)
+				start = p
+			}
+		}
+
+		if sync {
+			e.flush()
+		}
+	}
+	e.flush()
+
+	// tools defined but never used
+	tools := []*ToolStats{}
+	for _, t := range rv.Tools {
+		if t.Time > 0 {
+			tools = append(tools, t)
+		}
+	}
+	rv.Tools = tools
+
+	return rv, nil
+}
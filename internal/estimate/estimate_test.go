@@ -0,0 +1,166 @@
+package estimate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+func testMachine() *Machine {
+	return &Machine{
+		MaxRate:           [3]float64{600, 600, 600},
+		Acceleration:      [3]float64{10, 10, 10},
+		JunctionDeviation: DefaultJunctionDeviation,
+		ArcTolerance:      DefaultArcTolerance,
+	}
+}
+
+func estimate(t *testing.T, data string) *Estimate {
+	t.Helper()
+
+	j, err := gcode.NewJobFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv, err := NewEstimate(j.Source(), *gcode.NewState(), testMachine())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func checkDuration(t *testing.T, name string, got time.Duration, want float64) {
+	t.Helper()
+
+	if math.Abs(got.Seconds()-want) > 1e-3 {
+		t.Errorf("%s: got %.4fs, want %.4fs", name, got.Seconds(), want)
+	}
+}
+
+// trapezoid returns the duration of a single motion that starts and ends
+// stopped, reaching the nominal speed (10mm/s) with 10mm/s^2.
+func trapezoid(length float64) float64 {
+	return 1 + 1 + (length-10)/10
+}
+
+func TestProfiles(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		want float64
+	}{
+		// 5mm to accelerate and decelerate, 90mm at 10mm/s
+		{"trapezoid", "G1 X100 F600", trapezoid(100)},
+
+		// the nominal speed is never reached, peak speed is sqrt(a*d)
+		{"triangle", "G1 X2 F600", 2 * math.Sqrt(10*2) / 10},
+
+		// rapids use the maximum rate
+		{"rapid", "G0 X100", trapezoid(100)},
+
+		// limits are per axis, the speed and acceleration of diagonal
+		// motions are sqrt(2) higher
+		{"rapid diagonal", "G0 X100 Y100", trapezoid(100)},
+
+		// feed rates are limited by the maximum rate
+		{"feed limited", "G1 X100 F6000", trapezoid(100)},
+
+		// inverse time, the nominal speed covers the motion in 1/F minutes
+		{"inverse time", "G93 G1 X100 F6", trapezoid(100)},
+
+		{"inches", "G20 G1 X3.937 F23.622", trapezoid(3.937 * 25.4)},
+		{"dwell", "G4 P2.5", 2.5},
+	} {
+		e := estimate(t, tt.data)
+		checkDuration(t, tt.name, e.Time, tt.want)
+	}
+}
+
+func TestJunctions(t *testing.T) {
+	// speed at a 90 degrees corner, like grbl's plan_buffer_line()
+	sin := math.Sqrt(0.5 * (1 - 0))
+	accel := 10 * math.Sqrt2
+	corner := math.Sqrt(accel * DefaultJunctionDeviation * sin / (1 - sin))
+
+	// time of a 50mm block from/to the corner speed
+	half := 1 + (10-corner)/10 + (50-5-(100-corner*corner)/20)/10
+
+	for _, tt := range []struct {
+		name string
+		data string
+		want float64
+	}{
+		{"collinear", "G1 X50 F600\nX100", trapezoid(100)},
+		{"reversal", "G1 X50 F600\nX0", 2 * trapezoid(50)},
+		{"corner", "G1 X50 F600\nY50", 2 * half},
+
+		// grbl waits for the motions to complete before a dwell
+		{"sync", "G1 X50 F600\nG4 P0\nX100", 2 * trapezoid(50)},
+
+		// the planner only looks 16 blocks ahead, enough to stop from
+		// 10mm/s in 16mm
+		{"many blocks", manyBlocks(100), trapezoid(100)},
+	} {
+		e := estimate(t, tt.data)
+		checkDuration(t, tt.name, e.Time, tt.want)
+	}
+}
+
+func manyBlocks(n int) string {
+	rv := "G1 F600\n"
+	for i := 1; i <= n; i++ {
+		rv += "G91 X1\n"
+	}
+	return rv
+}
+
+func TestTools(t *testing.T) {
+	e := estimate(t, "T1 M6\nG1 X100 F600\n(T only selects the next tool)\nT2\nG1 X0\nM6\nG0 X100\nG4 P1\n")
+
+	if len(e.Tools) != 2 {
+		t.Fatalf("got %d tools, want 2", len(e.Tools))
+	}
+	if e.Tools[0].Tool != 1 || e.Tools[1].Tool != 2 {
+		t.Fatalf("unexpected tools: T%.0f, T%.0f", e.Tools[0].Tool, e.Tools[1].Tool)
+	}
+
+	t1, t2 := e.Tools[0], e.Tools[1]
+	checkDuration(t, "T1 cutting", t1.CuttingTime, 2*trapezoid(100))
+	checkDuration(t, "T2 travel", t2.TravelTime, trapezoid(100))
+	checkDuration(t, "T2 dwell", t2.DwellTime, 1)
+	if t1.CuttingDistance != 200 || t2.TravelDistance != 100 {
+		t.Errorf("unexpected distances: %g, %g", t1.CuttingDistance, t2.TravelDistance)
+	}
+	checkDuration(t, "total", e.Time, 3*trapezoid(100)+1)
+	if e.Lines != 8 {
+		t.Errorf("got %d lines, want 8", e.Lines)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	j, err := gcode.NewJobFromData("G1 X10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEstimate(j.Source(), *gcode.NewState(), testMachine()); err == nil {
+		t.Error("expected error for undefined feed rate")
+	}
+	if _, err := NewEstimate(j.Source(), *gcode.NewState(), nil); err == nil {
+		t.Error("expected error for undefined machine")
+	}
+
+	settings := map[uint8]float64{110: 600, 111: 600, 112: 600, 120: 10, 121: 10, 122: 10}
+	if _, err := NewMachine(settings); err != nil {
+		t.Error(err)
+	}
+	delete(settings, 122)
+	if _, err := NewMachine(settings); err == nil {
+		t.Error("expected error for missing setting")
+	}
+	settings[122] = 0
+	if _, err := NewMachine(settings); err == nil {
+		t.Error("expected error for invalid setting")
+	}
+}
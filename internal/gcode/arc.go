@@ -0,0 +1,165 @@
+package gcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// DefaultArcTolerance is the default maximum distance between an arc and
+// the chords used to approximate it.
+const DefaultArcTolerance = 0.01
+
+// Arc is a circular (or helical) arc in the XY plane (G17), as defined by
+// a G2/G3 motion.
+type Arc struct {
+	Start     *point.Point
+	End       *point.Point
+	Center    *point.Point
+	Radius    float64
+	Clockwise bool
+
+	// angular travel in radians, negative for clockwise arcs
+	Sweep float64
+}
+
+// NewArc creates an arc from start to end, using the I/J (center offset)
+// or R (radius) words of the line. end must be the target position
+// already resolved for the motion, and the I/J/R values must use the same
+// units as start and end.
+func NewArc(start *point.Point, end *point.Point, l Line, clockwise bool) (*Arc, error) {
+	if start == nil || end == nil {
+		return nil, errors.New("gcode: arc: undefined start or end position")
+	}
+
+	if l.Get('K') != nil {
+		return nil, errors.New("gcode: arc: only the XY plane (G17) is supported")
+	}
+
+	i, j := l.Get('I'), l.Get('J')
+	r := l.Get('R')
+
+	rv := &Arc{
+		Start:     start.Copy(),
+		End:       end.Copy(),
+		Clockwise: clockwise,
+	}
+
+	var ci, cj float64
+
+	switch {
+	case r != nil:
+		if i != nil || j != nil {
+			return nil, errors.New("gcode: arc: both radius and center offsets defined")
+		}
+
+		// same as grbl's gc_execute_line()
+		x := end.X - start.X
+		y := end.Y - start.Y
+		if x == 0 && y == 0 {
+			return nil, errors.New("gcode: arc: radius arcs can't be full circles")
+		}
+
+		radius := r.Value
+		h := 4*radius*radius - x*x - y*y
+		if h < 0 {
+			return nil, fmt.Errorf("gcode: arc: invalid radius: %.4f", radius)
+		}
+		h = -math.Sqrt(h) / math.Hypot(x, y)
+		if !clockwise {
+			h = -h
+		}
+		if radius < 0 {
+			h = -h
+		}
+
+		ci = 0.5 * (x - y*h)
+		cj = 0.5 * (y + x*h)
+
+	case i != nil || j != nil:
+		if i != nil {
+			ci = i.Value
+		}
+		if j != nil {
+			cj = j.Value
+		}
+
+	default:
+		return nil, errors.New("gcode: arc: no radius or center offsets defined")
+	}
+
+	rv.Center = &point.Point{
+		X: start.X + ci,
+		Y: start.Y + cj,
+		Z: start.Z,
+	}
+	rv.Radius = math.Hypot(ci, cj)
+	if rv.Radius == 0 {
+		return nil, errors.New("gcode: arc: zero radius")
+	}
+
+	// the end point must be on the circle, with the same tolerance used by
+	// grbl (0.005mm or 0.1%).
+	endRadius := math.Hypot(end.X-rv.Center.X, end.Y-rv.Center.Y)
+	if diff := math.Abs(endRadius - rv.Radius); diff > 0.005 && diff > 0.001*rv.Radius {
+		return nil, errors.New("gcode: arc: invalid target, not on the arc circle")
+	}
+
+	a0 := math.Atan2(start.Y-rv.Center.Y, start.X-rv.Center.X)
+	a1 := math.Atan2(end.Y-rv.Center.Y, end.X-rv.Center.X)
+
+	// same as grbl's mc_arc(), start and end at the same point are full
+	// circles.
+	const epsilon = 5e-7
+	rv.Sweep = a1 - a0
+	if clockwise {
+		if rv.Sweep >= -epsilon {
+			rv.Sweep -= 2 * math.Pi
+		}
+	} else if rv.Sweep <= epsilon {
+		rv.Sweep += 2 * math.Pi
+	}
+
+	return rv, nil
+}
+
+// Length returns the length of the arc, including the Z travel of
+// helical arcs.
+func (a *Arc) Length() float64 {
+	return math.Hypot(math.Abs(a.Sweep)*a.Radius, a.End.Z-a.Start.Z)
+}
+
+// Points returns the end points of the chords that approximate the arc
+// within the given tolerance, not including the start point.
+func (a *Arc) Points(tolerance float64) []*point.Point {
+	if tolerance <= 0 {
+		tolerance = DefaultArcTolerance
+	}
+
+	n := 1
+	if tolerance < a.Radius {
+		step := 2 * math.Acos(1-tolerance/a.Radius)
+		n = int(math.Ceil(math.Abs(a.Sweep) / step))
+		if n < 1 {
+			n = 1
+		}
+	}
+
+	a0 := math.Atan2(a.Start.Y-a.Center.Y, a.Start.X-a.Center.X)
+
+	rv := make([]*point.Point, 0, n)
+	for k := 1; k < n; k++ {
+		t := float64(k) / float64(n)
+		angle := a0 + a.Sweep*t
+		rv = append(rv, &point.Point{
+			X: a.Center.X + a.Radius*math.Cos(angle),
+			Y: a.Center.Y + a.Radius*math.Sin(angle),
+			Z: a.Start.Z + (a.End.Z-a.Start.Z)*t,
+		})
+	}
+
+	// use the exact end point, to avoid accumulating rounding errors.
+	return append(rv, a.End.Copy())
+}
@@ -0,0 +1,163 @@
+package gcode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+func mustLine(t *testing.T, s string) Line {
+	t.Helper()
+
+	l, err := parseLine(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestNewArc(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		end       *point.Point
+		line      string
+		clockwise bool
+		center    *point.Point
+		sweep     float64
+	}{
+		{"radius cw", &point.Point{X: 10, Y: 10}, "R10", true, &point.Point{X: 10, Y: 0}, -math.Pi / 2},
+		{"radius ccw", &point.Point{X: 10, Y: 10}, "R10", false, &point.Point{X: 0, Y: 10}, math.Pi / 2},
+
+		// negative radius selects the arc longer than half circle
+		{"negative radius cw", &point.Point{X: 10, Y: 10}, "R-10", true, &point.Point{X: 0, Y: 10}, -3 * math.Pi / 2},
+		{"negative radius ccw", &point.Point{X: 10, Y: 10}, "R-10", false, &point.Point{X: 10, Y: 0}, 3 * math.Pi / 2},
+
+		{"offsets cw", &point.Point{X: 20, Y: 0}, "I10 J0", true, &point.Point{X: 10, Y: 0}, -math.Pi},
+		{"offsets ccw", &point.Point{X: 20, Y: 0}, "I10", false, &point.Point{X: 10, Y: 0}, math.Pi},
+		{"offsets j only", &point.Point{X: 5, Y: 5}, "J5", false, &point.Point{X: 0, Y: 5}, math.Pi / 2},
+
+		// start and end at the same point
+		{"full circle cw", &point.Point{}, "I5", true, &point.Point{X: 5, Y: 0}, -2 * math.Pi},
+		{"full circle ccw", &point.Point{}, "I-5 J0", false, &point.Point{X: -5, Y: 0}, 2 * math.Pi},
+
+		// end slightly off the circle, within grbl's tolerance
+		{"end tolerance", &point.Point{X: 20.004, Y: 0}, "I10", true, &point.Point{X: 10, Y: 0}, -math.Pi},
+	} {
+		a, err := NewArc(&point.Point{}, tt.end, mustLine(t, tt.line), tt.clockwise)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+
+		if math.Abs(a.Center.X-tt.center.X) > 1e-9 || math.Abs(a.Center.Y-tt.center.Y) > 1e-9 {
+			t.Errorf("%s: got center %s, want %s", tt.name, a.Center, tt.center)
+		}
+		if math.Abs(a.Sweep-tt.sweep) > 1e-6 {
+			t.Errorf("%s: got sweep %.6f, want %.6f", tt.name, a.Sweep, tt.sweep)
+		}
+		if want := math.Abs(tt.sweep) * a.Radius; math.Abs(a.Length()-want) > 1e-6 {
+			t.Errorf("%s: got length %.6f, want %.6f", tt.name, a.Length(), want)
+		}
+	}
+}
+
+func TestNewArcErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		end  *point.Point
+		line string
+	}{
+		{"no words", &point.Point{X: 10}, "G2"},
+		{"radius and offsets", &point.Point{X: 10}, "R5 I5"},
+		{"radius full circle", &point.Point{}, "R5"},
+		{"radius too small", &point.Point{X: 10, Y: 10}, "R1"},
+		{"zero radius", &point.Point{X: 10}, "I0 J0"},
+		{"end off the circle", &point.Point{X: 21}, "I10"},
+
+		// G18 and G19 arcs use the K word
+		{"xz plane", &point.Point{X: 10}, "I5 K0"},
+		{"yz plane", &point.Point{Y: 10}, "J5 K0"},
+	} {
+		if _, err := NewArc(&point.Point{}, tt.end, mustLine(t, tt.line), true); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	if _, err := NewArc(nil, &point.Point{}, mustLine(t, "I5"), true); err == nil {
+		t.Error("expected error for undefined start")
+	}
+}
+
+func TestArcPlanes(t *testing.T) {
+	// arcs are only supported in the XY plane
+	for _, plane := range []string{"G18", "G19"} {
+		st := NewState()
+		if _, err := st.Process(mustLine(t, "G21 G90 G0 X0 Y0 Z0")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := st.Process(mustLine(t, plane)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := st.Process(mustLine(t, "G2 X10 Z0 I5 K0")); err == nil {
+			t.Errorf("%s: expected error", plane)
+		}
+	}
+}
+
+func TestArcHelix(t *testing.T) {
+	a, err := NewArc(&point.Point{Z: 1}, &point.Point{X: 20, Z: -2}, mustLine(t, "I10"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := math.Hypot(10*math.Pi, 3); math.Abs(a.Length()-want) > 1e-9 {
+		t.Errorf("got length %.6f, want %.6f", a.Length(), want)
+	}
+
+	pts := a.Points(0.01)
+	for i, p := range pts {
+		// ccw from (0, 0) around (10, 0), through (10, -10)
+		if p.Y > 1e-9 {
+			t.Errorf("point %d on the wrong side: %s", i, p)
+		}
+		if i > 0 && p.Z >= pts[i-1].Z {
+			t.Errorf("point %d: Z not descending: %s", i, p)
+		}
+	}
+}
+
+func TestArcPoints(t *testing.T) {
+	a, err := NewArc(&point.Point{}, &point.Point{}, mustLine(t, "I10"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tolerance := range []float64{0.001, 0.01, 0.1, 1} {
+		pts := a.Points(tolerance)
+
+		last := pts[len(pts)-1]
+		if last.X != 0 || last.Y != 0 {
+			t.Errorf("tolerance %g: last point is not the end: %s", tolerance, last)
+		}
+
+		prev := a.Start
+		for i, p := range pts {
+			if r := math.Hypot(p.X-a.Center.X, p.Y-a.Center.Y); math.Abs(r-a.Radius) > 1e-9 {
+				t.Errorf("tolerance %g: point %d off the circle: %s", tolerance, i, p)
+			}
+
+			// distance from the middle of the chord to the arc
+			mid := &point.Point{X: (prev.X + p.X) / 2, Y: (prev.Y + p.Y) / 2}
+			if d := a.Radius - math.Hypot(mid.X-a.Center.X, mid.Y-a.Center.Y); d > tolerance+1e-9 {
+				t.Errorf("tolerance %g: chord %d too far from the arc: %g", tolerance, i, d)
+			}
+			prev = p
+		}
+	}
+
+	// radius smaller than the tolerance
+	if pts := a.Points(20); len(pts) != 1 {
+		t.Errorf("got %d points, want 1", len(pts))
+	}
+}
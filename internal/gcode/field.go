@@ -4,35 +4,58 @@ import (
 	"fmt"
 	"math"
 	"strconv"
-	"strings"
-	"unicode"
 )
 
+// Field is a word of a g-code line, or a comment, if Letter is '(' or ';'.
 type Field struct {
-	Letter rune
-	Value  float64
+	Letter  rune
+	Value   float64
+	Comment string
 }
 
 func NewField(field string) (*Field, error) {
-	field = strings.TrimSpace(field)
-	if field == "" || !unicode.IsLetter(rune(field[0])) {
-		return nil, fmt.Errorf("gcode: bad field: %s", field)
-	}
-
-	v, err := strconv.ParseFloat(field[1:], 64)
+	l, err := parseLine(field, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Field{
-		Letter: rune(field[0]),
-		Value:  v,
-	}, nil
+	if len(l) != 1 || l[0].IsComment() {
+		return nil, fmt.Errorf("gcode: bad field: %s", field)
+	}
+	return &l[0], nil
+}
+
+func (f Field) IsComment() bool {
+	return f.Letter == '(' || f.Letter == ';'
 }
 
-func (f *Field) String() string {
+// appendTo appends the field formatted as g-code to b, avoiding the
+// allocations of String when formatting whole lines.
+func (f Field) appendTo(b []byte) []byte {
+	if f.IsComment() {
+		return b
+	}
+
+	b = append(b, byte(f.Letter))
 	if f.Value == math.Trunc(f.Value) {
-		return fmt.Sprintf("%c%.0f", f.Letter, f.Value)
+		return strconv.AppendFloat(b, f.Value, 'f', 0, 64)
+	}
+	return strconv.AppendFloat(b, f.Value, 'f', 4, 64)
+}
+
+// String returns the field formatted as g-code. comments are only
+// included by Text.
+func (f Field) String() string {
+	return string(f.appendTo(nil))
+}
+
+// Text returns the field formatted as g-code, including comments.
+func (f Field) Text() string {
+	switch f.Letter {
+	case '(':
+		return "(" + f.Comment + ")"
+	case ';':
+		return ";" + f.Comment
 	}
-	return fmt.Sprintf("%c%.4f", f.Letter, f.Value)
+	return f.String()
 }
@@ -1,7 +1,6 @@
 package gcode
 
 import (
-	"bufio"
 	"errors"
 	"io"
 	"math"
@@ -11,30 +10,10 @@ import (
 
 type Job []Line
 
+// NewJob parses a g-code program. blank lines and program delimiters are
+// dropped, comment-only lines are kept.
 func NewJob(reader io.Reader) (Job, error) {
-	// this is based on gcode generated by flatcam.
-
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanLines)
-
-	rv := []Line{}
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if line == "" || strings.HasPrefix(line, "(") {
-			continue
-		}
-
-		l, err := NewLine(line)
-		if err != nil {
-			return nil, err
-		}
-
-		rv = append(rv, l)
-	}
-
-	return rv, nil
+	return ReadJob(NewReader(reader))
 }
 
 func NewJobFromFile(fname string) (Job, error) {
@@ -52,36 +31,69 @@ func NewJobFromData(data string) (Job, error) {
 }
 
 func (j Job) String() string {
-	rv := ""
+	rv := strings.Builder{}
 	for _, l := range j {
-		rv += l.String() + "\n"
+		rv.WriteString(l.Text())
+		rv.WriteByte('\n')
 	}
-	return rv
+	return rv.String()
+}
+
+// GetBoundingBox returns the XY area covered by the motions of the job,
+// starting from the given state, in mm.
+func (j Job) GetBoundingBox(st State) (float64, float64, float64, float64, error) {
+	return GetBoundingBox(j.Source(), st)
 }
 
-func (j Job) GetBoundingBox() (float64, float64, float64, float64, error) {
+// GetBoundingBox returns the XY area covered by the motions read from src,
+// starting from the given state, in mm.
+func GetBoundingBox(src Source, st State) (float64, float64, float64, float64, error) {
 	minX := math.Inf(1)
 	minY := math.Inf(1)
 	maxX := math.Inf(-1)
 	maxY := math.Inf(-1)
 
-	for _, l := range j {
-		if x := l.Get('X'); x != nil {
-			if x.Value < minX {
-				minX = x.Value
-			}
-			if x.Value > maxX {
-				maxX = x.Value
-			}
+	addX := func(v float64) {
+		minX = math.Min(minX, v)
+		maxX = math.Max(maxX, v)
+	}
+	addY := func(v float64) {
+		minY = math.Min(minY, v)
+		maxY = math.Max(maxY, v)
+	}
+
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			break
 		}
-		if y := l.Get('Y'); y != nil {
-			if y.Value < minY {
-				minY = y.Value
-			}
-			if y.Value > maxY {
-				maxY = y.Value
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+
+		m, err := st.Process(l)
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+
+		if m == nil || m.Mode == 4 {
+			continue
+		}
+
+		// arcs may extend beyond their end points
+		if m.Arc != nil {
+			for _, p := range m.Arc.Points(DefaultArcTolerance) {
+				addX(p.X)
+				addY(p.Y)
 			}
 		}
+
+		if st.Known[0] {
+			addX(m.End.X)
+		}
+		if st.Known[1] {
+			addY(m.End.Y)
+		}
 	}
 
 	if math.IsInf(minX, 1) || math.IsInf(minY, 1) || math.IsInf(maxX, -1) || math.IsInf(maxY, -1) {
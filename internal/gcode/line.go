@@ -1,42 +1,85 @@
 package gcode
 
 import (
-	"bufio"
 	"strings"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
 )
 
-type Line []*Field
+// Line is a line of g-code. fields are stored by value, to keep large jobs
+// compact in memory.
+type Line []Field
 
+// NewLine parses a line of g-code. comments are kept as fields, and
+// checksums are validated and dropped.
 func NewLine(line string) (Line, error) {
-	scanner := bufio.NewScanner(strings.NewReader(line))
-	scanner.Split(bufio.ScanWords)
-
-	rv := []*Field{}
-	for scanner.Scan() {
-		f, err := NewField(scanner.Text())
-		if err != nil {
-			return nil, err
-		}
-		rv = append(rv, f)
+	return parseLine(line, 0)
+}
+
+// String returns the line in the compact format sent to grbl, without
+// spaces and comments.
+func (l Line) String() string {
+	return string(l.appendTo(nil))
+}
+
+func (l Line) appendTo(b []byte) []byte {
+	for _, f := range l {
+		b = f.appendTo(b)
 	}
+	return b
+}
 
-	return Line(rv), nil
+// Text returns the line in a human readable format, including comments.
+func (l Line) Text() string {
+	rv := []string{}
+	eol := ""
+	for _, f := range l {
+		if f.Letter == ';' {
+			eol = f.Text()
+			continue
+		}
+		rv = append(rv, f.Text())
+	}
+	if eol != "" {
+		rv = append(rv, eol)
+	}
+	return strings.Join(rv, " ")
 }
 
-func (l Line) String() string {
-	rv := ""
+// IsEmpty returns true if the line has no words, only comments.
+func (l Line) IsEmpty() bool {
 	for _, f := range l {
-		rv += f.String()
+		if !f.IsComment() {
+			return false
+		}
 	}
-	return rv
+	return true
+}
+
+// Copy returns a copy of the line, that can be modified without changing
+// the original line.
+func (l Line) Copy() Line {
+	return append(make(Line, 0, len(l)), l...)
 }
 
+// Get returns the first field of the line with the given letter, if any.
+// the field points to the line storage, and can be used to modify it.
 func (l Line) Get(letter rune) *Field {
-	for _, f := range l {
-		if f.Letter == letter {
-			return f
+	for i := range l {
+		if l[i].Letter == letter {
+			return &l[i]
+		}
+	}
+
+	return nil
+}
+
+// GetMotion returns the motion mode field (G0, G1, G2 or G3) of the line,
+// if any.
+func (l Line) GetMotion() *Field {
+	for i, f := range l {
+		if f.Letter == 'G' && (f.Value == 0. || f.Value == 1. || f.Value == 2. || f.Value == 3.) {
+			return &l[i]
 		}
 	}
 
@@ -44,8 +87,29 @@ func (l Line) Get(letter rune) *Field {
 }
 
 func (l Line) IsMotion() bool {
-	g := l.Get('G')
-	return g != nil && (g.Value == 0. || g.Value == 1.) // there's more supported by flatcam?
+	return l.GetMotion() != nil
+}
+
+func (l Line) IsArc() bool {
+	g := l.GetMotion()
+	return g != nil && (g.Value == 2. || g.Value == 3.)
+}
+
+// IsNonModalAxis returns true if the line has a non-modal command that
+// uses its axis words for something other than a regular motion (G10,
+// G28, G30, G53 or G92).
+func (l Line) IsNonModalAxis() bool {
+	for _, f := range l {
+		if f.Letter != 'G' {
+			continue
+		}
+
+		switch f.Value {
+		case 10, 28, 30, 53, 92:
+			return true
+		}
+	}
+	return false
 }
 
 func (l Line) HasPosition() bool {
@@ -60,7 +124,7 @@ func (l *Line) SetPosition(p *point.Point) {
 	if x := l.Get('X'); x != nil {
 		x.Value = p.X
 	} else {
-		*l = append(*l, &Field{
+		*l = append(*l, Field{
 			Letter: 'X',
 			Value:  p.X,
 		})
@@ -69,7 +133,7 @@ func (l *Line) SetPosition(p *point.Point) {
 	if y := l.Get('Y'); y != nil {
 		y.Value = p.Y
 	} else {
-		*l = append(*l, &Field{
+		*l = append(*l, Field{
 			Letter: 'Y',
 			Value:  p.Y,
 		})
@@ -78,7 +142,7 @@ func (l *Line) SetPosition(p *point.Point) {
 	if z := l.Get('Z'); z != nil {
 		z.Value = p.Z
 	} else {
-		*l = append(*l, &Field{
+		*l = append(*l, Field{
 			Letter: 'Z',
 			Value:  p.Z,
 		})
@@ -0,0 +1,198 @@
+package gcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseError is a syntax error found while parsing g-code. Line is 0 when
+// parsing a single line.
+type ParseError struct {
+	Line   int
+	Column int
+	Err    string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("gcode: line %d, column %d: %s", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("gcode: column %d: %s", e.Column, e.Err)
+}
+
+// letters of the words supported by grbl. others, like the E in numbers
+// with exponents (1e3), are rejected instead of parsed as separate words.
+const wordLetters = "FGIJKLMNPRSTXYZ"
+
+type parser struct {
+	text   string
+	lineno int
+	pos    int
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &ParseError{
+		Line:   p.lineno,
+		Column: pos + 1,
+		Err:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// number parses a number in the format accepted by grbl: an optional sign,
+// followed by digits with an optional decimal point.
+func (p *parser) number(letter rune) (float64, error) {
+	start := p.pos
+	if p.pos < len(p.text) && (p.text[p.pos] == '+' || p.text[p.pos] == '-') {
+		p.pos++
+	}
+
+	digits := 0
+	dot := false
+	for ; p.pos < len(p.text); p.pos++ {
+		c := p.text[p.pos]
+		if c >= '0' && c <= '9' {
+			digits++
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+	}
+
+	if digits == 0 {
+		return 0, p.errorf(start, "bad number format for word %c", letter)
+	}
+
+	v, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		return 0, p.errorf(start, "bad number format for word %c", letter)
+	}
+	return v, nil
+}
+
+// checksum validates a RepRap-style checksum, the XOR of all the bytes of
+// the line before the '*'.
+func (p *parser) checksum() error {
+	start := p.pos
+	p.pos++
+
+	end := p.pos
+	for end < len(p.text) && p.text[end] >= '0' && p.text[end] <= '9' {
+		end++
+	}
+	if end == p.pos {
+		return p.errorf(start, "bad checksum format")
+	}
+
+	expected, err := strconv.Atoi(p.text[p.pos:end])
+	if err != nil {
+		return p.errorf(start, "bad checksum format")
+	}
+	p.pos = end
+
+	sum := 0
+	for i := 0; i < start; i++ {
+		sum ^= int(p.text[i])
+	}
+	if sum != expected {
+		return p.errorf(start, "checksum mismatch: expected %d, got %d", expected, sum)
+	}
+	return nil
+}
+
+// comment parses a comment. the text is copied, to avoid keeping the whole
+// line in memory.
+func (p *parser) comment() (Field, error) {
+	start := p.pos
+
+	if p.text[p.pos] == ';' {
+		p.pos = len(p.text)
+		return Field{
+			Letter:  ';',
+			Comment: string([]byte(strings.TrimSpace(p.text[start+1:]))),
+		}, nil
+	}
+
+	end := strings.IndexByte(p.text[start:], ')')
+	if end < 0 {
+		return Field{}, p.errorf(start, "unterminated comment")
+	}
+	p.pos = start + end + 1
+
+	return Field{
+		Letter:  '(',
+		Comment: string([]byte(strings.TrimSpace(p.text[start+1 : start+end]))),
+	}, nil
+}
+
+func (p *parser) parse() (Line, error) {
+	rv := Line{}
+
+	// program delimiters, the text after them is ignored
+	if strings.HasPrefix(strings.TrimSpace(p.text), "%") {
+		return rv, nil
+	}
+
+	checksum := false
+	for p.skipSpaces(); p.pos < len(p.text); p.skipSpaces() {
+		c := rune(p.text[p.pos])
+
+		switch {
+		case c == '(' || c == ';':
+			f, err := p.comment()
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, f)
+
+		// only comments are allowed after the checksum
+		case checksum:
+			return nil, p.errorf(p.pos, "unexpected character after checksum: %q", c)
+
+		case c == '*':
+			if err := p.checksum(); err != nil {
+				return nil, err
+			}
+			checksum = true
+
+		case unicode.IsLetter(c) && c < unicode.MaxASCII:
+			letter := unicode.ToUpper(c)
+			if !strings.ContainsRune(wordLetters, letter) {
+				return nil, p.errorf(p.pos, "unsupported word: %c", letter)
+			}
+			p.pos++
+			p.skipSpaces()
+
+			v, err := p.number(letter)
+			if err != nil {
+				return nil, err
+			}
+
+			rv = append(rv, Field{
+				Letter: letter,
+				Value:  v,
+			})
+
+		default:
+			return nil, p.errorf(p.pos, "unexpected character: %q", c)
+		}
+	}
+
+	return rv, nil
+}
+
+func parseLine(text string, lineno int) (Line, error) {
+	p := &parser{
+		text:   text,
+		lineno: lineno,
+	}
+	return p.parse()
+}
@@ -0,0 +1,153 @@
+package gcode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withChecksum appends the RepRap-style checksum of a line to it.
+func withChecksum(l string) string {
+	sum := 0
+	for i := 0; i < len(l); i++ {
+		sum ^= int(l[i])
+	}
+	return fmt.Sprintf("%s*%d", l, sum)
+}
+
+func TestParseLine(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		str  string
+		text string
+	}{
+		{"", "", ""},
+		{"   ", "", ""},
+		{"G1 X10 Y-2.5 F100", "G1X10Y-2.5000F100", "G1 X10 Y-2.5000 F100"},
+		{"g1x.5y+1.", "G1X0.5000Y1", "G1 X0.5000 Y1"},
+		{"G0 X 1 Y\t2", "G0X1Y2", "G0 X1 Y2"},
+		{"G0X1.23456", "G0X1.2346", "G0 X1.2346"},
+		{"M3 S1000", "M3S1000", "M3 S1000"},
+		{"T1 M6", "T1M6", "T1 M6"},
+
+		// comments
+		{"(comment)", "", "(comment)"},
+		{"; comment", "", ";comment"},
+		{"G0 (a) X1 ( b )", "G0X1", "G0 (a) X1 (b)"},
+		{"G0 X1 ; end (not a comment)", "G0X1", "G0 X1 ;end (not a comment)"},
+		{"; end\tof line", "", ";end\tof line"},
+
+		// program delimiters
+		{"%", "", ""},
+		{" % ", "", ""},
+		{"%program start", "", ""},
+		{"% (comment)", "", ""},
+
+		// checksums
+		{withChecksum("N1 G1 X1"), "N1G1X1", "N1 G1 X1"},
+		{withChecksum("N2 G0 X1 (c)"), "N2G0X1", "N2 G0 X1 (c)"},
+		{withChecksum("N3 G0 Y2") + " (after) ; end", "N3G0Y2", "N3 G0 Y2 (after) ;end"},
+	} {
+		l, err := parseLine(tt.line, 0)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.line, err)
+			continue
+		}
+		if s := l.String(); s != tt.str {
+			t.Errorf("%q: got string %q, want %q", tt.line, s, tt.str)
+		}
+		if s := l.Text(); s != tt.text {
+			t.Errorf("%q: got text %q, want %q", tt.line, s, tt.text)
+		}
+	}
+}
+
+func TestParseLineRoundTrip(t *testing.T) {
+	for _, line := range []string{
+		"G1 X10 Y-2.5 F100",
+		"G2 X1.2346 Y-0.0001 I0.5000 J-3 (arc)",
+		"N10 G90 G21 ;setup",
+		"G38.2 Z-10 F50",
+		"G10 L20 P1 X0 Y0 Z0",
+		"(only a comment)",
+	} {
+		l, err := parseLine(line, 0)
+		if err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+
+		for _, s := range []string{l.String(), l.Text()} {
+			l2, err := parseLine(s, 0)
+			if err != nil {
+				t.Errorf("%q: reparsing %q: %s", line, s, err)
+				continue
+			}
+			if l2.String() != l.String() {
+				t.Errorf("%q: reparsing %q: got %q, want %q", line, s, l2.String(), l.String())
+			}
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		err  string
+	}{
+		{"G1 X1e3", "gcode: column 6: unsupported word: E"},
+		{"G1 A10", "gcode: column 4: unsupported word: A"},
+		{"O100", "gcode: column 1: unsupported word: O"},
+		{"G1 X", "gcode: column 5: bad number format for word X"},
+		{"G1 X-", "gcode: column 5: bad number format for word X"},
+		{"G1 X.", "gcode: column 5: bad number format for word X"},
+		{"G1 X1.2.3", "gcode: column 8: unexpected character: '.'"},
+		{"G1 X1 #1", "gcode: column 7: unexpected character: '#'"},
+		{"G1 X1 (open", "gcode: column 7: unterminated comment"},
+		{"G1 X1 %", "gcode: column 7: unexpected character: '%'"},
+		{"N1 G1 X1*5", "gcode: column 9: checksum mismatch: expected 5, got 96"},
+		{"G1 X1*", "gcode: column 6: bad checksum format"},
+		{"G1 X1*a", "gcode: column 6: bad checksum format"},
+		{withChecksum("G1 X1") + " G0", "gcode: column 10: unexpected character after checksum: 'G'"},
+		{withChecksum("G1 X1") + " (open", "gcode: column 10: unterminated comment"},
+	} {
+		_, err := parseLine(tt.line, 0)
+		if err == nil {
+			t.Errorf("%q: expected error", tt.line)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%q: got error %q, want %q", tt.line, err, tt.err)
+		}
+	}
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		err  string
+	}{
+		{"G0 X1\nG1 Y1e2\n", "gcode: line 2, column 6: unsupported word: E"},
+		{"%\n(header)\n\nG0 X1 (open\n", "gcode: line 4, column 7: unterminated comment"},
+		{"G0 X1\r\nG1 X\r\n", "gcode: line 2, column 5: bad number format for word X"},
+	} {
+		_, err := NewJobFromData(tt.data)
+		if err == nil {
+			t.Errorf("%q: expected error", tt.data)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%q: got error %q, want %q", tt.data, err, tt.err)
+		}
+	}
+
+	r := NewReader(strings.NewReader("%\n(header)\n\nG0 X1\n\n% end\nG1 Y1\n"))
+	want := []int{2, 4, 7}
+	for i, n := range want {
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("line %d: %s", i+1, err)
+		}
+		if r.LineNumber() != n {
+			t.Errorf("line %d: got file line number %d, want %d", i+1, r.LineNumber(), n)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gcode
+
+import (
+	"bufio"
+	"io"
+)
+
+// Source is an iterator over the lines of a g-code program, that allows
+// processing large programs without loading them in memory.
+type Source interface {
+	// Next returns the next line of the program, or io.EOF after the last
+	// one.
+	Next() (Line, error)
+}
+
+// Reader is a Source that parses a program from an io.Reader, one line at
+// a time. blank lines and program delimiters (lines starting with %) are
+// skipped, comment-only lines are kept.
+type Reader struct {
+	scanner *bufio.Scanner
+	lineno  int
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	return &Reader{
+		scanner: scanner,
+	}
+}
+
+func (r *Reader) Next() (Line, error) {
+	for r.scanner.Scan() {
+		r.lineno++
+
+		l, err := parseLine(r.scanner.Text(), r.lineno)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(l) > 0 {
+			return l, nil
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// LineNumber returns the number of the last line read, in the source
+// file.
+func (r *Reader) LineNumber() int {
+	return r.lineno
+}
+
+type jobSource struct {
+	j Job
+	i int
+}
+
+func (s *jobSource) Next() (Line, error) {
+	if s.i >= len(s.j) {
+		return nil, io.EOF
+	}
+
+	s.i++
+	return s.j[s.i-1], nil
+}
+
+// Source returns a Source that iterates over the lines of the job.
+func (j Job) Source() Source {
+	return &jobSource{
+		j: j,
+	}
+}
+
+// ReadJob reads all the lines of a Source into a Job.
+func ReadJob(src Source) (Job, error) {
+	rv := Job{}
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return rv, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rv = append(rv, l)
+	}
+}
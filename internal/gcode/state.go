@@ -0,0 +1,395 @@
+package gcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// State is the modal state of a g-code program, tracking the same modal
+// groups as grbl. g-codes and m-codes are stored as their numbers, e.g.
+// Units is 20 or 21.
+type State struct {
+	Motion      float64 // G0, G1, G2, G3, G38.2-G38.5, G80
+	Plane       float64 // G17, G18, G19
+	Units       float64 // G20, G21
+	Distance    float64 // G90, G91
+	ArcDistance float64 // G90.1, G91.1
+	FeedMode    float64 // G93, G94
+	WCS         float64 // G54-G59
+	Spindle     float64 // M3, M4, M5
+	Mist        bool    // M7
+	Flood       bool    // M8
+	Tool        float64 // T
+
+	// Feed is in mm/min, or in 1/min in inverse time mode (G93).
+	Feed  float64
+	Speed float64
+
+	// Position is the current position, absolute and in mm, in the
+	// current work coordinate system. the position of each axis is only
+	// valid if known by the program.
+	Position point.Point
+	Known    [3]bool
+}
+
+// Motion is a motion commanded by a line.
+type Motion struct {
+	// Mode is the motion mode (0, 1, 2, 3 or 38.x), or 4 for dwells.
+	Mode float64
+
+	// Start and End are absolute and in mm, and only valid if StartKnown
+	// and EndKnown are true, respectively.
+	Start      *point.Point
+	End        *point.Point
+	StartKnown bool
+	EndKnown   bool
+
+	// Arc is only set for G2/G3 motions with known start and end.
+	Arc *Arc
+
+	// Feed is the feed rate (see State.Feed), Dwell is in seconds.
+	Feed  float64
+	Dwell float64
+}
+
+// Length returns the distance travelled by the motion, in mm. it is only
+// meaningful if the start and end positions are known.
+func (m *Motion) Length() float64 {
+	if m.Mode == 4 {
+		return 0
+	}
+	if m.Arc != nil {
+		return m.Arc.Length()
+	}
+
+	d := m.End.Sub(m.Start)
+	return math.Sqrt(d.X*d.X + d.Y*d.Y + d.Z*d.Z)
+}
+
+// NewState returns the state of grbl after a reset.
+func NewState() *State {
+	return &State{
+		Motion:      0,
+		Plane:       17,
+		Units:       21,
+		Distance:    90,
+		ArcDistance: 91.1,
+		FeedMode:    94,
+		WCS:         54,
+		Spindle:     5,
+	}
+}
+
+// NewStateFromReport parses the parser state reported by grbl's $G command
+// (the content of the [GC:...] message).
+func NewStateFromReport(report string) (*State, error) {
+	l, err := NewLine(report)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := NewState()
+	if _, err := rv.Process(l); err != nil {
+		return nil, err
+	}
+
+	units, distance := false, false
+	for _, f := range l {
+		if f.Letter == 'G' {
+			units = units || f.Value == 20 || f.Value == 21
+			distance = distance || f.Value == 90 || f.Value == 91
+		}
+	}
+
+	if !units || !distance {
+		return nil, fmt.Errorf("gcode: incomplete parser state report: %s", report)
+	}
+
+	return rv, nil
+}
+
+func (s *State) String() string {
+	rv := []string{
+		(&Field{Letter: 'G', Value: s.Motion}).String(),
+		(&Field{Letter: 'G', Value: s.WCS}).String(),
+		(&Field{Letter: 'G', Value: s.Plane}).String(),
+		(&Field{Letter: 'G', Value: s.Units}).String(),
+		(&Field{Letter: 'G', Value: s.Distance}).String(),
+		(&Field{Letter: 'G', Value: s.FeedMode}).String(),
+		(&Field{Letter: 'M', Value: s.Spindle}).String(),
+	}
+
+	if s.Mist {
+		rv = append(rv, "M7")
+	}
+	if s.Flood {
+		rv = append(rv, "M8")
+	}
+	if !s.Mist && !s.Flood {
+		rv = append(rv, "M9")
+	}
+
+	feed := s.Feed
+	if s.FeedMode != 93 {
+		feed = s.FromMM(feed)
+	}
+
+	rv = append(rv,
+		(&Field{Letter: 'T', Value: s.Tool}).String(),
+		(&Field{Letter: 'F', Value: feed}).String(),
+		(&Field{Letter: 'S', Value: s.Speed}).String(),
+	)
+
+	return strings.Join(rv, " ")
+}
+
+// ToMM converts a length in the current units to mm.
+func (s *State) ToMM(v float64) float64 {
+	if s.Units == 20 {
+		return v * 25.4
+	}
+	return v
+}
+
+// FromMM converts a length in mm to the current units.
+func (s *State) FromMM(v float64) float64 {
+	if s.Units == 20 {
+		return v / 25.4
+	}
+	return v
+}
+
+// PositionKnown returns true if the position of all the axes is known.
+func (s *State) PositionKnown() bool {
+	return s.Known[0] && s.Known[1] && s.Known[2]
+}
+
+func axis(p *point.Point, i int) float64 {
+	return [3]float64{p.X, p.Y, p.Z}[i]
+}
+
+func setAxis(p *point.Point, i int, v float64) {
+	switch i {
+	case 0:
+		p.X = v
+	case 1:
+		p.Y = v
+	case 2:
+		p.Z = v
+	}
+}
+
+func (s *State) forget() {
+	s.Known = [3]bool{}
+}
+
+// Process updates the state with the words of a line, in the same order
+// used by grbl, and returns the motion commanded by it, if any.
+func (s *State) Process(l Line) (*Motion, error) {
+	var (
+		nonModal   float64 = -1
+		axisWords          = [3]*Field{}
+		hasAxis    bool
+		programEnd bool
+	)
+
+	for i, f := range l {
+		switch f.Letter {
+		case 'G':
+			switch f.Value {
+			case 0, 1, 2, 3, 38.2, 38.3, 38.4, 38.5, 80:
+				s.Motion = f.Value
+			case 17, 18, 19:
+				s.Plane = f.Value
+			case 20, 21:
+				s.Units = f.Value
+			case 90, 91:
+				s.Distance = f.Value
+			case 90.1, 91.1:
+				s.ArcDistance = f.Value
+			case 93, 94:
+				s.FeedMode = f.Value
+			case 54, 55, 56, 57, 58, 59:
+				if s.WCS != f.Value {
+					s.forget()
+				}
+				s.WCS = f.Value
+			case 4, 10, 28, 28.1, 30, 30.1, 53, 92, 92.1:
+				nonModal = f.Value
+			}
+
+		case 'M':
+			switch f.Value {
+			case 2, 30:
+				programEnd = true
+			case 3, 4, 5:
+				s.Spindle = f.Value
+			case 7:
+				s.Mist = true
+			case 8:
+				s.Flood = true
+			case 9:
+				s.Mist = false
+				s.Flood = false
+			}
+
+		case 'T':
+			s.Tool = f.Value
+
+		case 'S':
+			s.Speed = f.Value
+
+		case 'X', 'Y', 'Z':
+			axisWords[f.Letter-'X'] = &l[i]
+			hasAxis = true
+		}
+	}
+
+	// feed rates depend on the units defined in the same line
+	if f := l.Get('F'); f != nil {
+		if s.FeedMode == 93 {
+			s.Feed = f.Value
+		} else {
+			s.Feed = s.ToMM(f.Value)
+		}
+	}
+
+	var rv *Motion
+
+	switch nonModal {
+	case 4:
+		p := l.Get('P')
+		if p == nil {
+			return nil, errors.New("gcode: dwell without P word")
+		}
+		rv = &Motion{
+			Mode:  4,
+			Dwell: p.Value,
+		}
+
+	case 10:
+		// G10 L20 sets the current position, G10 L2 changes the
+		// offsets, making the current position unknown if it is the
+		// current coordinate system.
+		lw, pw := l.Get('L'), l.Get('P')
+		current := pw != nil && (pw.Value == 0 || pw.Value+53 == s.WCS)
+		if lw != nil && current {
+			for i, f := range axisWords {
+				if f == nil {
+					continue
+				}
+				if lw.Value == 20 {
+					setAxis(&s.Position, i, s.ToMM(f.Value))
+					s.Known[i] = true
+				} else {
+					s.Known[i] = false
+				}
+			}
+		}
+
+	case 92:
+		for i, f := range axisWords {
+			if f != nil {
+				setAxis(&s.Position, i, s.ToMM(f.Value))
+				s.Known[i] = true
+			}
+		}
+
+	case 28, 30, 92.1:
+		s.forget()
+
+	case 53:
+		for i, f := range axisWords {
+			if f != nil {
+				s.Known[i] = false
+			}
+		}
+
+	case -1:
+		if !hasAxis || s.Motion == 80 {
+			break
+		}
+
+		rv = &Motion{
+			Mode:       s.Motion,
+			Start:      s.Position.Copy(),
+			StartKnown: s.PositionKnown(),
+			Feed:       s.Feed,
+		}
+
+		for i, f := range axisWords {
+			if f == nil {
+				continue
+			}
+
+			v := s.ToMM(f.Value)
+			if s.Distance == 91 {
+				// incremental moves from an unknown position keep it
+				// unknown.
+				setAxis(&s.Position, i, axis(&s.Position, i)+v)
+			} else {
+				setAxis(&s.Position, i, v)
+				s.Known[i] = true
+			}
+		}
+
+		rv.End = s.Position.Copy()
+		rv.EndKnown = s.PositionKnown()
+
+		switch s.Motion {
+		case 2, 3:
+			if s.Plane != 17 {
+				return nil, errors.New("gcode: arcs are only supported in the XY plane (G17)")
+			}
+
+			if rv.StartKnown && rv.EndKnown {
+				arc, err := NewArc(rv.Start, rv.End, s.arcLine(l), s.Motion == 2)
+				if err != nil {
+					return nil, err
+				}
+				rv.Arc = arc
+			}
+
+		case 38.2, 38.3, 38.4, 38.5:
+			// probing stops somewhere before the target
+			for i, f := range axisWords {
+				if f != nil {
+					s.Known[i] = false
+				}
+			}
+			rv.EndKnown = false
+		}
+	}
+
+	if programEnd {
+		s.Motion = 1
+		s.Plane = 17
+		s.Distance = 90
+		s.FeedMode = 94
+		if s.WCS != 54 {
+			s.forget()
+		}
+		s.WCS = 54
+		s.Spindle = 5
+		s.Mist = false
+		s.Flood = false
+	}
+
+	return rv, nil
+}
+
+// arcLine returns a copy of the line with the arc words converted to mm.
+func (s *State) arcLine(l Line) Line {
+	rv := l.Copy()
+	for i := range rv {
+		switch rv[i].Letter {
+		case 'I', 'J', 'K', 'R':
+			rv[i].Value = s.ToMM(rv[i].Value)
+		}
+	}
+	return rv
+}
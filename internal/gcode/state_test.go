@@ -0,0 +1,171 @@
+package gcode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// process returns the state after processing the lines of a program.
+func process(t *testing.T, data string) *State {
+	t.Helper()
+
+	j, err := NewJobFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewState()
+	for _, l := range j {
+		if _, err := st.Process(l); err != nil {
+			t.Fatalf("%s: %s", l, err)
+		}
+	}
+	return st
+}
+
+func TestStatePosition(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		position point.Point
+		known    [3]bool
+	}{
+		{"reset", "", point.Point{}, [3]bool{}},
+		{"absolute", "G90 G0 X1 Y2\nG1 Z-1 F100", point.Point{X: 1, Y: 2, Z: -1}, [3]bool{true, true, true}},
+		{"partial", "G90 G0 X1", point.Point{X: 1}, [3]bool{true, false, false}},
+		{"inches", "G20 G90 G0 X1 Y-0.5 Z0", point.Point{X: 25.4, Y: -12.7}, [3]bool{true, true, true}},
+		{"incremental", "G90 G0 X1 Y1 Z1\nG91 G0 X1\nX1 Z-2", point.Point{X: 3, Y: 1, Z: -1}, [3]bool{true, true, true}},
+
+		// incremental moves from an unknown position keep it unknown
+		{"incremental unknown", "G91 G0 X1 Y1", point.Point{X: 1, Y: 1}, [3]bool{}},
+
+		// G92 sets the current position
+		{"G92", "G90 G0 X10 Y10 Z10\nG92 X0 Y0", point.Point{X: 0, Y: 0, Z: 10}, [3]bool{true, true, true}},
+		{"G92 unknown", "G92 X1 Z2", point.Point{X: 1, Z: 2}, [3]bool{true, false, true}},
+		{"G92 inches", "G20 G90 G0 X1 Y1 Z1\nG92 Z0", point.Point{X: 25.4, Y: 25.4}, [3]bool{true, true, true}},
+
+		// G92.1 clears the offsets, moving the work coordinates
+		{"G92.1", "G90 G0 X10 Y10 Z10\nG92 X0 Y0 Z0\nG92.1", point.Point{}, [3]bool{}},
+
+		// G10 L20 sets the current position in the given coordinate
+		// system, G10 L2 sets its offsets
+		{"G10 L20", "G90 G0 X10 Y10 Z10\nG10 L20 P1 X0 Y0", point.Point{X: 0, Y: 0, Z: 10}, [3]bool{true, true, true}},
+		{"G10 L20 P0", "G55 G90 G0 X10 Y10 Z10\nG10 L20 P0 Z5", point.Point{X: 10, Y: 10, Z: 5}, [3]bool{true, true, true}},
+		{"G10 L20 other wcs", "G90 G0 X10 Y10 Z10\nG10 L20 P2 X0 Y0", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{true, true, true}},
+		{"G10 L2", "G90 G0 X10 Y10 Z10\nG10 L2 P1 X5", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{false, true, true}},
+		{"G10 L2 other wcs", "G90 G0 X10 Y10 Z10\nG10 L2 P3 X5 Y5 Z5", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{true, true, true}},
+
+		// switching the coordinate system moves the work coordinates
+		{"wcs switch", "G90 G0 X10 Y10 Z10\nG55", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{}},
+		{"same wcs", "G90 G0 X10 Y10 Z10\nG54", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{true, true, true}},
+		{"program end", "G55 G90 G0 X10 Y10 Z10\nM2", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{}},
+
+		// machine coordinates, homing and probing
+		{"G53", "G90 G0 X10 Y10 Z10\nG53 G0 Z-1", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{true, true, false}},
+		{"G28", "G90 G0 X10 Y10 Z10\nG28", point.Point{X: 10, Y: 10, Z: 10}, [3]bool{}},
+		{"probe", "G90 G0 X10 Y10 Z10\nG38.2 Z-10 F50", point.Point{X: 10, Y: 10, Z: -10}, [3]bool{true, true, false}},
+	} {
+		st := process(t, tt.data)
+
+		if st.Known != tt.known {
+			t.Errorf("%s: got known %v, want %v", tt.name, st.Known, tt.known)
+		}
+		for i := 0; i < 3; i++ {
+			if tt.known[i] && math.Abs(axis(&st.Position, i)-axis(&tt.position, i)) > 1e-9 {
+				t.Errorf("%s: got position %s, want %s", tt.name, &st.Position, &tt.position)
+				break
+			}
+		}
+	}
+}
+
+func TestStateModal(t *testing.T) {
+	st := process(t, "G20 G91 G93 G55 G18\nM4 S1000 T2\nM7 M8\nG1 X1 F2")
+	if want := "G1 G55 G18 G20 G91 G93 M4 M7 M8 T2 F2 S1000"; st.String() != want {
+		t.Errorf("got state %q, want %q", st, want)
+	}
+	if st.Feed != 2 {
+		t.Errorf("inverse time feed rates are not converted: %g", st.Feed)
+	}
+
+	st = process(t, "G20 F10\nG21")
+	if math.Abs(st.Feed-254) > 1e-9 {
+		t.Errorf("got feed %g mm/min, want 254", st.Feed)
+	}
+
+	// program end restores some of the modal groups
+	st = process(t, "G20 G91 G93 G55 G18 G2\nM3 S1000 M8\nM30")
+	if want := "G1 G54 G17 G20 G90 G94 M5 M9 T0 F0 S1000"; st.String() != want {
+		t.Errorf("got state %q, want %q", st, want)
+	}
+
+	if _, err := NewState().Process(mustLine(t, "G4")); err == nil {
+		t.Error("expected error for dwell without P word")
+	}
+}
+
+func TestNewStateFromReport(t *testing.T) {
+	st, err := NewStateFromReport("G0 G56 G17 G20 G91 G94 M3 M8 T1 F100 S500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "G0 G56 G17 G20 G91 G94 M3 M8 T1 F100 S500"; st.String() != want {
+		t.Errorf("got state %q, want %q", st, want)
+	}
+	if st.PositionKnown() {
+		t.Error("position must be unknown")
+	}
+
+	if _, err := NewStateFromReport("G0 G54 G17"); err == nil {
+		t.Error("expected error for incomplete report")
+	}
+}
+
+func TestStateMotion(t *testing.T) {
+	st := process(t, "G21 G90 G0 X0 Y0 Z0")
+
+	m, err := st.Process(mustLine(t, "G1 X3 Y4 F100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Mode != 1 || !m.StartKnown || !m.EndKnown || m.Length() != 5 || m.Feed != 100 {
+		t.Errorf("unexpected motion: %+v", m)
+	}
+
+	// modal motion
+	m, err = st.Process(mustLine(t, "X0 Y0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Mode != 1 || m.Length() != 5 {
+		t.Errorf("unexpected motion: %+v", m)
+	}
+
+	m, err = st.Process(mustLine(t, "G2 X10 I5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Arc == nil || math.Abs(m.Length()-5*math.Pi) > 1e-9 {
+		t.Errorf("unexpected arc motion: %+v", m)
+	}
+
+	m, err = st.Process(mustLine(t, "G4 P1.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Mode != 4 || m.Dwell != 1.5 || m.Length() != 0 {
+		t.Errorf("unexpected dwell: %+v", m)
+	}
+
+	for _, l := range []string{"G80", "G80 X1", "M3 S100", "G92 X0"} {
+		m, err := st.Process(mustLine(t, l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != nil {
+			t.Errorf("%s: unexpected motion: %+v", l, m)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package gcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Transform is an affine transformation of the XY plane, that maps (x, y)
+// to (A*x + B*y + C, D*x + E*y + F).
+type Transform struct {
+	A, B, C float64
+	D, E, F float64
+}
+
+func IdentityTransform() Transform {
+	return Transform{A: 1, E: 1}
+}
+
+// MirrorX returns a transform that mirrors X coordinates around the
+// vertical line at x.
+func MirrorX(x float64) Transform {
+	return Transform{A: -1, C: 2 * x, E: 1}
+}
+
+// MirrorY returns a transform that mirrors Y coordinates around the
+// horizontal line at y.
+func MirrorY(y float64) Transform {
+	return Transform{A: 1, E: -1, F: 2 * y}
+}
+
+// Rotation returns a transform that rotates counterclockwise by angle
+// (in radians) around (x, y).
+func Rotation(angle float64, x float64, y float64) Transform {
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	return Transform{
+		A: cos, B: -sin, C: x - cos*x + sin*y,
+		D: sin, E: cos, F: y - sin*x - cos*y,
+	}
+}
+
+func Translation(dx float64, dy float64) Transform {
+	return Transform{A: 1, C: dx, E: 1, F: dy}
+}
+
+// Then returns a transform that applies t and then o.
+func (t Transform) Then(o Transform) Transform {
+	return Transform{
+		A: o.A*t.A + o.B*t.D,
+		B: o.A*t.B + o.B*t.E,
+		C: o.A*t.C + o.B*t.F + o.C,
+		D: o.D*t.A + o.E*t.D,
+		E: o.D*t.B + o.E*t.E,
+		F: o.D*t.C + o.E*t.F + o.F,
+	}
+}
+
+func (t Transform) Apply(x float64, y float64) (float64, float64) {
+	return t.A*x + t.B*y + t.C, t.D*x + t.E*y + t.F
+}
+
+// ApplyVector transforms a displacement, ignoring the translation.
+func (t Transform) ApplyVector(dx float64, dy float64) (float64, float64) {
+	return t.A*dx + t.B*dy, t.D*dx + t.E*dy
+}
+
+// IsMirror returns true if the transform changes the orientation of the
+// plane, turning clockwise arcs into counterclockwise ones.
+func (t Transform) IsMirror() bool {
+	return t.A*t.E-t.B*t.D < 0
+}
+
+// set replaces the value of the first field with the given letter, or
+// appends a new field.
+func (l *Line) set(letter rune, v float64) {
+	if f := l.Get(letter); f != nil {
+		f.Value = v
+		return
+	}
+
+	*l = append(*l, Field{
+		Letter: letter,
+		Value:  v,
+	})
+}
+
+// roundField rounds a value to the precision used when formatting fields.
+func roundField(v float64) float64 {
+	// adding zero also gets rid of negative zeros
+	return math.Round(v*1e4)/1e4 + 0
+}
+
+// TransformJob applies a transform to the XY positions of a job, starting
+// from the given modal state. arcs are reversed by mirroring transforms.
+// lines that use XY words for anything other than motions (G10, G28, G30,
+// G53, G92) are not supported.
+func TransformJob(j Job, st State, t Transform) (Job, error) {
+	rv := make(Job, 0, len(j))
+	mirror := t.IsMirror()
+
+	// rounding errors carried between incremental moves, in mm
+	var carryX, carryY float64
+
+	for i, l := range j {
+		hasXY := l.Get('X') != nil || l.Get('Y') != nil
+		if hasXY && l.IsNonModalAxis() {
+			return nil, fmt.Errorf("gcode: transform: line %d: unsupported command with XY words: %s", i+1, l)
+		}
+
+		m, err := st.Process(l)
+		if err != nil {
+			return nil, fmt.Errorf("gcode: transform: line %d: %w", i+1, err)
+		}
+
+		arc := m != nil && (m.Mode == 2 || m.Mode == 3)
+		if m == nil || m.Mode == 4 || (!hasXY && !arc) {
+			rv = append(rv, l)
+			continue
+		}
+
+		nl := l.Copy()
+
+		if hasXY {
+			if st.Distance == 91 {
+				dx, dy := 0., 0.
+				if f := l.Get('X'); f != nil {
+					dx = st.ToMM(f.Value)
+				}
+				if f := l.Get('Y'); f != nil {
+					dy = st.ToMM(f.Value)
+				}
+
+				// keep the rounding errors from accumulating
+				dx, dy = t.ApplyVector(dx, dy)
+				x, y := roundField(st.FromMM(dx+carryX)), roundField(st.FromMM(dy+carryY))
+				carryX += dx - st.ToMM(x)
+				carryY += dy - st.ToMM(y)
+				nl.set('X', x)
+				nl.set('Y', y)
+			} else {
+				if !st.Known[0] || !st.Known[1] {
+					return nil, fmt.Errorf("gcode: transform: line %d: XY position unknown: %s", i+1, l)
+				}
+
+				x, y := t.Apply(st.Position.X, st.Position.Y)
+				nl.set('X', roundField(st.FromMM(x)))
+				nl.set('Y', roundField(st.FromMM(y)))
+			}
+		}
+
+		if arc {
+			if nl.Get('K') != nil {
+				return nil, errors.New("gcode: transform: only arcs in the XY plane (G17) are supported")
+			}
+
+			if nl.Get('I') != nil || nl.Get('J') != nil {
+				ci, cj := 0., 0.
+				if f := nl.Get('I'); f != nil {
+					ci = st.ToMM(f.Value)
+				}
+				if f := nl.Get('J'); f != nil {
+					cj = st.ToMM(f.Value)
+				}
+
+				if st.ArcDistance == 90.1 {
+					ci, cj = t.Apply(ci, cj)
+				} else {
+					ci, cj = t.ApplyVector(ci, cj)
+				}
+				nl.set('I', roundField(st.FromMM(ci)))
+				nl.set('J', roundField(st.FromMM(cj)))
+			}
+
+			if mirror {
+				// the motion mode is reversed in every arc line, so the
+				// modal state of grbl stays consistent.
+				g := 2.
+				if m.Mode == 2 {
+					g = 3
+				}
+				if f := nl.GetMotion(); f != nil {
+					f.Value = g
+				} else {
+					nl = append(Line{{Letter: 'G', Value: g}}, nl...)
+				}
+			}
+		}
+
+		rv = append(rv, nl)
+	}
+
+	return rv, nil
+}
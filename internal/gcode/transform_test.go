@@ -0,0 +1,171 @@
+package gcode
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func checkApply(t *testing.T, name string, tr Transform, x, y, wantX, wantY float64) {
+	t.Helper()
+
+	gotX, gotY := tr.Apply(x, y)
+	if math.Abs(gotX-wantX) > 1e-9 || math.Abs(gotY-wantY) > 1e-9 {
+		t.Errorf("%s: (%g, %g): got (%g, %g), want (%g, %g)", name, x, y, gotX, gotY, wantX, wantY)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	checkApply(t, "identity", IdentityTransform(), 1, 2, 1, 2)
+	checkApply(t, "mirror x", MirrorX(5), 1, 2, 9, 2)
+	checkApply(t, "mirror y", MirrorY(-1), 1, 2, 1, -4)
+	checkApply(t, "translation", Translation(1, -1), 1, 2, 2, 1)
+	checkApply(t, "rotation", Rotation(math.Pi/2, 1, 1), 2, 1, 1, 2)
+	checkApply(t, "rotation origin", Rotation(math.Pi/4, 0, 0), 1, 1, 0, math.Sqrt2)
+
+	// transforms are applied in order
+	tr := MirrorX(0).Then(Translation(10, 0))
+	checkApply(t, "mirror then translation", tr, 1, 2, 9, 2)
+	tr = Translation(10, 0).Then(MirrorX(0))
+	checkApply(t, "translation then mirror", tr, 1, 2, -11, 2)
+
+	dx, dy := Rotation(math.Pi/2, 5, 5).Then(Translation(3, 3)).ApplyVector(1, 0)
+	if math.Abs(dx) > 1e-9 || math.Abs(dy-1) > 1e-9 {
+		t.Errorf("vectors ignore the translation: got (%g, %g)", dx, dy)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		t      Transform
+		mirror bool
+	}{
+		{"identity", IdentityTransform(), false},
+		{"mirror x", MirrorX(1), true},
+		{"mirror y", MirrorY(1), true},
+		{"rotation", Rotation(1, 2, 3), false},
+		{"mirror and rotation", MirrorX(1).Then(Rotation(1, 2, 3)), true},
+		{"double mirror", MirrorX(1).Then(MirrorY(2)), false},
+	} {
+		if tt.t.IsMirror() != tt.mirror {
+			t.Errorf("%s: got mirror %t, want %t", tt.name, !tt.mirror, tt.mirror)
+		}
+	}
+}
+
+func transformJob(t *testing.T, data string, tr Transform) string {
+	t.Helper()
+
+	j, err := NewJobFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv, err := TransformJob(j, *NewState(), tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the transformed arcs must still be valid
+	st := NewState()
+	for _, l := range rv {
+		if _, err := st.Process(l); err != nil {
+			t.Fatalf("%s: %s", l, err)
+		}
+	}
+	return rv.String()
+}
+
+func TestTransformJob(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		t    Transform
+		want string
+	}{
+		{
+			// arcs are reversed in every line, including modal ones. missing
+			// words are appended
+			name: "mirror arcs",
+			data: "G21 G90\nG0 X0 Y0 Z1\nG2 X10 Y0 I5 J0\nX0 Y0 I-5\nG3 X10 Y0 R5 (radius)\nG1 X20 F100\n",
+			t:    MirrorX(0),
+			want: "G21 G90\nG0 X0 Y0 Z1\nG3 X-10 Y0 I-5 J0\nG3 X0 Y0 I5 J0\nG2 X-10 Y0 R5 (radius)\nG1 X-20 F100 Y0\n",
+		},
+		{
+			name: "mirror y",
+			data: "G21 G90\nG0 X1 Y1\nG3 X1 Y3 J1\n",
+			t:    MirrorY(0),
+			want: "G21 G90\nG0 X1 Y-1\nG2 X1 Y-3 J-1 I0\n",
+		},
+		{
+			name: "rotation",
+			data: "G21 G90\nG0 X0 Y0\nG1 X10 F100\nG2 X20 I5\n",
+			t:    Rotation(math.Pi/2, 0, 0),
+			want: "G21 G90\nG0 X0 Y0\nG1 X0 F100 Y10\nG2 X0 I0 Y20 J5\n",
+		},
+		{
+			name: "incremental",
+			data: "G21 G90\nG0 X0 Y0\nG91 G1 X1 Y2 F100\nX-1\n",
+			t:    MirrorX(5).Then(Translation(1, 1)),
+			want: "G21 G90\nG0 X11 Y1\nG91 G1 X-1 Y2 F100\nX1 Y0\n",
+		},
+		{
+			name: "inches",
+			data: "G20 G90\nG0 X1 Y0\n",
+			t:    Translation(25.4, 12.7),
+			want: "G20 G90\nG0 X2 Y0.5000\n",
+		},
+		{
+			// lines without XY words are kept
+			name: "no xy",
+			data: "G21 G90\nG0 X0 Y0\nG1 Z-1 F100\nG4 P1\nM3 S1000\n",
+			t:    Translation(1, 1),
+			want: "G21 G90\nG0 X1 Y1\nG1 Z-1 F100\nG4 P1\nM3 S1000\n",
+		},
+	} {
+		if got := transformJob(t, tt.data, tt.t); got != tt.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTransformJobIncrementalRounding(t *testing.T) {
+	data := "G21 G90\nG0 X0 Y0\nG91\n" + strings.Repeat("G1 X0.1 Y0.1 F100\n", 1000)
+	tr := Rotation(math.Pi/6, 0, 0)
+
+	j, err := NewJobFromData(transformJob(t, data, tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewState()
+	for _, l := range j {
+		if _, err := st.Process(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantX, wantY := tr.Apply(100, 100)
+	if math.Abs(st.Position.X-wantX) > 1e-4 || math.Abs(st.Position.Y-wantY) > 1e-4 {
+		t.Errorf("got position %s, want X=%.4f,Y=%.4f", &st.Position, wantX, wantY)
+	}
+}
+
+func TestTransformJobErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{"G92", "G21 G90\nG0 X0 Y0\nG92 X1 Y1\n"},
+		{"G28", "G21 G90\nG28 X0 Y0\n"},
+		{"G53", "G21 G90\nG53 G0 X0\n"},
+		{"G10", "G21 G90\nG10 L2 P1 X0\n"},
+		{"position unknown", "G21 G90\nG0 X1\n"},
+		{"xz plane", "G21 G90\nG0 X0 Y0 Z0\nG18\nG2 X10 I5 K0\n"},
+	} {
+		j, err := NewJobFromData(tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := TransformJob(j, *NewState(), MirrorX(0)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package grbl
+
+import (
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+// AlarmError is returned by commands and jobs interrupted by an alarm.
+type AlarmError struct {
+	Alarm response.Alarm
+}
+
+func (e *AlarmError) Error() string {
+	return fmt.Sprintf("grbl: alarm %d: %s", e.Alarm.Code, e.Alarm.Message)
+}
+
+// isCriticalAlarm returns true for alarms that require a reset before
+// grbl accepts anything else.
+func isCriticalAlarm(code uint8) bool {
+	return code == response.AlarmHardLimitError || code == response.AlarmSoftLimitError
+}
+
+func (g *Grbl) alarmChan() <-chan struct{} {
+	g.RLock()
+	defer g.RUnlock()
+
+	return g.alarm
+}
+
+// alarmError returns an AlarmError instead of err if an alarm was
+// triggered after the alarm channel was retrieved.
+func (g *Grbl) alarmError(alarm <-chan struct{}, err error) error {
+	select {
+	case <-alarm:
+	default:
+		return err
+	}
+
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.LastAlarm == nil {
+		return err
+	}
+	return &AlarmError{
+		Alarm: *g.LastAlarm,
+	}
+}
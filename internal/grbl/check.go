@@ -0,0 +1,42 @@
+package grbl
+
+import (
+	"context"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+)
+
+// SetCheckMode enables or disables grbl's check mode ($C), where g-code
+// lines are validated without moving the machine. grbl is reset when the
+// check mode is disabled, and the gcode state goes back to its defaults.
+func (g *Grbl) SetCheckMode(ctx context.Context, enabled bool) error {
+	if err := g.RefreshStatus(ctx); err != nil {
+		return err
+	}
+
+	g.RLock()
+	current := g.State == response.StateCheck
+	g.RUnlock()
+
+	if current == enabled {
+		return nil
+	}
+
+	reset := g.resetChan()
+	if err := g.SendCommands(ctx, "$C"); err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+
+	// commands sent before grbl finishes resetting are lost
+	select {
+	case <-reset:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.done:
+		return g.err
+	}
+}
@@ -1,6 +1,11 @@
 package grbl
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
 
 const (
 	ErrorExpectedCommandLetter       = 1
@@ -82,10 +87,14 @@ var (
 	}
 )
 
+var (
+	ErrReset = errors.New("grbl: controller was reset")
+)
+
 type Error uint8
 
 func NewError(id uint8) Error {
-	return 0
+	return Error(id)
 }
 
 func (e Error) Error() string {
@@ -94,3 +103,18 @@ func (e Error) Error() string {
 	}
 	return fmt.Sprintf("grbl: error: unknown (%d)", e)
 }
+
+// LineError is an error returned by grbl for a given line of a job.
+type LineError struct {
+	Index int
+	Line  gcode.Line
+	Err   error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("grbl: line %d (%s): %s", e.Index+1, e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
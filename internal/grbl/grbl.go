@@ -5,47 +5,91 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
-	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/usbserial"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
 )
 
+// DefaultStatusInterval is the default interval between status report
+// requests sent to grbl.
+const DefaultStatusInterval = 200 * time.Millisecond
+
+// Grbl talks to a grbl controller through a Transport. Its exported state
+// is updated by a background reader goroutine, and the embedded lock must
+// be held to read it while the controller is running.
 type Grbl struct {
-	serial   *usbserial.UsbSerial
-	handlers response.ResponseHandlers
-	ignore   []*gcode.Field
+	sync.RWMutex
 
-	State     response.StateType
-	StateName string
-	WCO       *point.Point
-	MPos      *point.Point
-	WPos      *point.Point
-	Settings  map[uint8]float64
+	t        Transport
+	handlers response.ResponseHandlers
+	ignore   []gcode.Field
+
+	acks          chan error
+	done          chan struct{}
+	quit          chan struct{}
+	err           error
+	interval      chan time.Duration
+	statusEvery   time.Duration
+	statusWaiters []chan struct{}
+	reset         chan struct{}
+	alarm         chan struct{}
+	paused        chan struct{}
+
+	State       response.StateType
+	SubState    int
+	StateName   string
+	WCO         *point.Point
+	MPos        *point.Point
+	WPos        *point.Point
+	Buffer      *response.Buffer
+	Line        *int
+	FeedRate    float64
+	Spindle     float64
+	Pins        response.Pins
+	Overrides   *response.Overrides
+	Accessories *response.Accessories
+	Settings    map[uint8]float64
 
 	Version    string
 	LastProbe  *point.Point
 	LastAlarm  *response.Alarm
-	GCodeState *GCodeStates
+	GCodeState *gcode.State
+
+	// offsets reported by grbl ($#), in mm
+	CoordinateSystems [6]*point.Point // G54-G59
+	G28               *point.Point
+	G30               *point.Point
+	G92               *point.Point
+	TLO               *float64
+
+	// Streaming enables the character-counting streaming protocol for
+	// jobs, instead of waiting for the response of each line.
+	Streaming bool
+
+	// ToolChange is called when a job reaches a line with a M6 command,
+	// that grbl doesn't support, after all the previous lines were
+	// acknowledged. the line is sent without the M6 word afterwards.
+	ToolChange func(ctx context.Context, l gcode.Line) error
 }
 
-func NewGrbl(device string) (*Grbl, error) {
-	serial, err := usbserial.Open(device)
-	if err != nil {
-		return nil, err
+func NewGrbl(t Transport) (*Grbl, error) {
+	if t == nil {
+		return nil, errors.New("grbl: transport not defined")
 	}
 
-	if err := serial.Flush(); err != nil {
+	if err := t.Flush(); err != nil {
 		return nil, err
 	}
 
 	rv := &Grbl{
-		serial: serial,
-		ignore: []*gcode.Field{
+		t: t,
+		ignore: []gcode.Field{
 			{
 				Letter: 'M',
 				Value:  0,
@@ -56,12 +100,24 @@ func NewGrbl(device string) (*Grbl, error) {
 			},
 		},
 
+		acks:     make(chan error, rxBufferSize),
+		done:     make(chan struct{}),
+		quit:     make(chan struct{}),
+		reset:    make(chan struct{}),
+		alarm:    make(chan struct{}),
+		interval: make(chan time.Duration),
+
+		statusEvery: DefaultStatusInterval,
+
 		Settings: map[uint8]float64{},
 	}
 	rv.handlers = []response.ResponseHandler{
 		&response.StatusHandler{
 			Callback: rv.StatusHandler,
 		},
+		&response.ParameterHandler{
+			Callback: rv.ParameterHandler,
+		},
 		&response.MessageHandler{
 			Callback: rv.MessageHandler,
 		},
@@ -76,11 +132,25 @@ func NewGrbl(device string) (*Grbl, error) {
 		},
 	}
 
+	go rv.readLoop()
+	go rv.pollStatus(DefaultStatusInterval)
+
 	// it takes some status calls (or a reset) for grbl to retrieve the WCO
-	for rv.WCO == nil {
-		if err := rv.SendCommands(context.Background(), "?"); err != nil {
+	for {
+		// status requests may be lost while grbl boots, retry them.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := rv.RefreshStatus(ctx)
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
 			return nil, err
 		}
+
+		rv.RLock()
+		found := rv.WCO != nil
+		rv.RUnlock()
+		if found {
+			break
+		}
 	}
 
 	// populate gcode states
@@ -92,15 +162,29 @@ func NewGrbl(device string) (*Grbl, error) {
 }
 
 func (g *Grbl) Close() error {
-	if g.serial == nil {
+	if g.t == nil {
 		return nil
 	}
-	return g.serial.Close()
+
+	select {
+	case <-g.quit:
+		return nil
+	default:
+	}
+
+	close(g.quit)
+	return g.t.Close()
 }
 
 func (g *Grbl) StatusHandler(status *response.Status) error {
+	defer g.notifyStatus()
+
 	g.State = status.State
+	g.SubState = status.SubState
 	g.StateName = status.StateName
+	g.FeedRate = status.FeedRate
+	g.Spindle = status.Spindle
+	g.Pins = status.Pins
 
 	if status.WCO != nil {
 		g.WCO = status.WCO.Copy()
@@ -114,6 +198,26 @@ func (g *Grbl) StatusHandler(status *response.Status) error {
 		g.WPos = status.WPos.Copy()
 	}
 
+	if status.Buffer != nil {
+		bf := *status.Buffer
+		g.Buffer = &bf
+	}
+
+	if status.Line != nil {
+		ln := *status.Line
+		g.Line = &ln
+	}
+
+	if status.Overrides != nil {
+		ov := *status.Overrides
+		g.Overrides = &ov
+	}
+
+	if status.Accessories != nil {
+		acc := *status.Accessories
+		g.Accessories = &acc
+	}
+
 	return nil
 }
 
@@ -123,15 +227,20 @@ func (g *Grbl) MessageHandler(msg *response.Message) error {
 		log.Print("message: ", msg.Content)
 
 	case "GC":
-		if g.GCodeState == nil {
-			var err error
-			g.GCodeState, err = NewGCodeStates(msg.Content)
-			if err != nil {
-				return nil
-			}
-			log.Print("gcode state: ", *g.GCodeState)
+		st, err := gcode.NewStateFromReport(msg.Content)
+		if err != nil {
+			return err
 		}
 
+		// the report doesn't include the position, keep the one
+		// tracked from the lines sent.
+		if g.GCodeState != nil {
+			st.Position = g.GCodeState.Position
+			st.Known = g.GCodeState.Known
+		}
+		g.GCodeState = st
+		log.Print("gcode state: ", st)
+
 	case "PRB":
 		parts := strings.Split(msg.Content, ":")
 		if len(parts) != 2 {
@@ -156,10 +265,39 @@ func (g *Grbl) MessageHandler(msg *response.Message) error {
 	return nil
 }
 
+func (g *Grbl) ParameterHandler(param *response.Parameter) error {
+	switch param.Name {
+	case "G54", "G55", "G56", "G57", "G58", "G59":
+		g.CoordinateSystems[param.Name[2]-'4'] = param.Position
+	case "G28":
+		g.G28 = param.Position
+	case "G30":
+		g.G30 = param.Position
+	case "G92":
+		g.G92 = param.Position
+	case "TLO":
+		tlo := param.Value
+		g.TLO = &tlo
+	default:
+		return fmt.Errorf("grbl: unsupported parameter: %s", param.Name)
+	}
+
+	return nil
+}
+
 func (g *Grbl) AlarmHandler(alarm *response.Alarm) error {
 	g.LastAlarm = alarm
-	log.Print("alarm: ", *alarm)
+	log.Printf("alarm: %d: %s", alarm.Code, alarm.Message)
+
+	// commands and jobs running are aborted
+	close(g.alarm)
+	g.alarm = make(chan struct{})
 
+	// grbl ignores everything but realtime commands after critical alarms,
+	// lines waiting for acknowledgement are only released by a reset.
+	if isCriticalAlarm(alarm.Code) {
+		return g.SoftReset()
+	}
 	return nil
 }
 
@@ -167,6 +305,19 @@ func (g *Grbl) BannerHandler(banner *response.Banner) error {
 	g.Version = banner.Version
 	log.Print("banner: ", *banner)
 
+	// grbl was reset, lines sent before won't be acknowledged anymore.
+	for len(g.acks) > 0 {
+		<-g.acks
+	}
+	close(g.reset)
+	g.reset = make(chan struct{})
+	g.resume()
+
+	// the gcode parser state is reinitialized by the reset
+	if g.GCodeState != nil {
+		g.GCodeState = gcode.NewState()
+	}
+
 	return nil
 }
 
@@ -178,49 +329,204 @@ func (g *Grbl) SettingHandler(setting *response.Setting) error {
 }
 
 func (g *Grbl) SendJob(ctx context.Context, j gcode.Job) error {
-	for _, l := range j {
+	return g.SendJobWithProgress(ctx, j, nil)
+}
+
+// SendJobWithProgress sends a job like SendJob, calling progress with the
+// index of each line acknowledged by grbl.
+func (g *Grbl) SendJobWithProgress(ctx context.Context, j gcode.Job, progress func(index int)) error {
+	return g.SendSource(ctx, j.Source(), progress)
+}
+
+// SendSource sends the lines read from src like SendJobWithProgress,
+// without loading them all in memory.
+func (g *Grbl) SendSource(ctx context.Context, src gcode.Source, progress func(index int)) error {
+	return g.SendSourceWithErrorHandler(ctx, src, progress, nil)
+}
+
+// LineErrorHandler is called when grbl reports an error for a line of a
+// job, unless an alarm was triggered. the job continues if it returns nil,
+// and is aborted with the returned error otherwise. when streaming, the
+// lines sent after the failed one are executed before it is called.
+type LineErrorHandler func(ctx context.Context, err *LineError) error
+
+// SendSourceWithErrorHandler sends the lines read from src like
+// SendSource, calling onError for the lines refused by grbl. if onError
+// is nil, the job is aborted on the first error.
+func (g *Grbl) SendSourceWithErrorHandler(ctx context.Context, src gcode.Source, progress func(index int), onError LineErrorHandler) error {
+	alarm := g.alarmChan()
+
+	if progress == nil {
+		progress = func(int) {}
+	}
+
+	if g.Streaming {
+		return g.alarmError(alarm, g.streamSource(ctx, src, progress, onError))
+	}
+	return g.alarmError(alarm, g.sendSource(ctx, src, progress, onError))
+}
+
+// sendSource sends the lines read from src using the send-response
+// protocol.
+func (g *Grbl) sendSource(ctx context.Context, src gcode.Source, progress func(index int), onError LineErrorHandler) error {
+	alarm := g.alarmChan()
+
+	for i := 0; ; i++ {
+		if err := g.waitResume(ctx); err != nil {
+			return err
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
 		}
 
-		if err := g.SendLine(l); err != nil {
+		l, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			return err
 		}
+
+		l, err = g.toolChange(ctx, l)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := g.sendJobLine(ctx, alarm, onError, i, l); err != nil {
+			return err
+		}
+		progress(i)
 	}
 	return nil
 }
 
-func (g *Grbl) SendLine(l gcode.Line) error {
-	if len(l) > 0 {
+// sendJobLine sends a line of a job using the send-response protocol,
+// handling grbl errors with onError.
+func (g *Grbl) sendJobLine(ctx context.Context, alarm <-chan struct{}, onError LineErrorHandler, index int, l gcode.Line) error {
+	err := g.SendLine(l)
+
+	var gerr Error
+	if !errors.As(err, &gerr) {
+		return err
+	}
+	return handleLineError(ctx, alarm, onError, &LineError{
+		Index: index,
+		Line:  l,
+		Err:   gerr,
+	})
+}
+
+// handleLineError returns the error that aborts a job after a grbl error,
+// or nil if the job should continue.
+func handleLineError(ctx context.Context, alarm <-chan struct{}, onError LineErrorHandler, err *LineError) error {
+	// lines are refused while in alarm state, the job can't continue
+	select {
+	case <-alarm:
+		return err
+	default:
+	}
+
+	if onError == nil {
+		return err
+	}
+	return onError(ctx, err)
+}
+
+func hasToolChange(l gcode.Line) bool {
+	for _, f := range l {
+		if f.Letter == 'M' && f.Value == 6 {
+			return true
+		}
+	}
+	return false
+}
+
+// stripToolChange returns the line without M6 commands, as sent to grbl.
+func stripToolChange(l gcode.Line) gcode.Line {
+	rv := gcode.Line{}
+	for _, f := range l {
+		if f.Letter != 'M' || f.Value != 6 {
+			rv = append(rv, f)
+		}
+	}
+	return rv
+}
+
+// toolChange calls the ToolChange callback for lines with a M6 command,
+// and returns the line without it.
+func (g *Grbl) toolChange(ctx context.Context, l gcode.Line) (gcode.Line, error) {
+	if !hasToolChange(l) {
+		return l, nil
+	}
+
+	rv := stripToolChange(l)
+
+	if g.ToolChange == nil {
+		log.Printf("grbl: ignoring tool change: %s", l)
+		return rv, nil
+	}
+
+	if err := g.ToolChange(ctx, l); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (g *Grbl) ignored(l gcode.Line) bool {
+	// comment-only lines
+	if l.IsEmpty() {
+		return true
+	}
+
+	for _, f := range l {
+		if f.IsComment() {
+			continue
+		}
 		for _, ign := range g.ignore {
-			if l[0].String() == ign.String() {
+			if f.String() == ign.String() {
 				log.Printf("grbl: ignoring g-code: %s", l)
-				return nil
+				return true
 			}
 		}
+		break
 	}
+	return false
+}
 
-	if err := g.send(l.String(), true); err != nil {
-		return err
+func (g *Grbl) SendLine(l gcode.Line) error {
+	if g.ignored(l) {
+		return nil
 	}
-	if g.GCodeState != nil {
-		g.GCodeState.ProcessLine(l)
+
+	if err := g.send(l.String()); err != nil {
+		return err
 	}
+	g.processGCodeState(l)
 	return nil
 }
 
-func (g *Grbl) SendRTCommand(cmd string) error {
-	if strings.ContainsAny(cmd, "\r\n") {
-		return errors.New("grbl: realtime commands should not have newlines")
-	}
+func (g *Grbl) processGCodeState(l gcode.Line) {
+	g.Lock()
+	defer g.Unlock()
 
-	// we just write command. response will be catched by the next streaming read.
-	return g.serial.WriteLine(strings.TrimSpace(cmd), false)
+	if g.GCodeState != nil {
+		// errors are reported by grbl itself
+		g.GCodeState.Process(l)
+	}
 }
 
 func (g *Grbl) SendCommands(ctx context.Context, cmds string) error {
+	alarm := g.alarmChan()
+	return g.alarmError(alarm, g.sendCommands(ctx, cmds))
+}
+
+func (g *Grbl) sendCommands(ctx context.Context, cmds string) error {
 	scanner := bufio.NewScanner(strings.NewReader(cmds))
 	scanner.Split(bufio.ScanLines)
 
@@ -231,7 +537,7 @@ func (g *Grbl) SendCommands(ctx context.Context, cmds string) error {
 		default:
 		}
 
-		if err := g.send(scanner.Text(), true); err != nil {
+		if err := g.send(scanner.Text()); err != nil {
 			return err
 		}
 	}
@@ -248,32 +554,16 @@ func (g *Grbl) SendGCodeInline(ctx context.Context, data string) error {
 	return g.SendJob(ctx, j)
 }
 
-func (g *Grbl) send(data string, nl bool) error {
-	if err := g.serial.WriteLine(data, nl); err != nil {
+func (g *Grbl) send(data string) error {
+	reset := g.resetChan()
+
+	if err := g.t.WriteLine(data); err != nil {
 		return err
 	}
 
-	for {
-		line, err := g.serial.ReadLine()
-		if err != nil {
-			return err
-		}
-
-		if line == "" {
-			continue
-		} else if line == "ok" {
-			return nil
-		} else if strings.HasPrefix(line, "error:") {
-			id, _ := strconv.Atoi(line[6:])
-			return Error(id)
-		}
-
-		if handler := g.handlers.Lookup(line); handler != nil {
-			if err := handler.Handle(line); err != nil {
-				log.Printf("error: grbl: %s", err)
-			}
-		} else {
-			log.Printf("warning: grbl: no handler found for response: %s", line)
-		}
+	ack, err := g.readAck(reset)
+	if err != nil {
+		return err
 	}
+	return ack
 }
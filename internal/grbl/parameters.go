@@ -0,0 +1,11 @@
+package grbl
+
+import (
+	"context"
+)
+
+// RefreshParameters requests the offsets and the last probe result from
+// grbl.
+func (g *Grbl) RefreshParameters(ctx context.Context) error {
+	return g.SendCommands(ctx, "$#")
+}
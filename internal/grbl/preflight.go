@@ -0,0 +1,124 @@
+package grbl
+
+import (
+	"io"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+// maximum length of a line accepted by grbl, without the newline
+const maxLineLength = 79
+
+// words supported by grbl, besides G and M commands
+const supportedWords = "FIJKLNPRSTXYZ"
+
+// modal groups of the g-code commands supported by grbl. non-modal
+// commands share a group, because only one of them is allowed per line.
+var (
+	gGroups = map[string][]float64{
+		"non-modal":           {4, 10, 28, 28.1, 30, 30.1, 53, 92, 92.1},
+		"motion":              {0, 1, 2, 3, 38.2, 38.3, 38.4, 38.5, 80},
+		"plane":               {17, 18, 19},
+		"distance":            {90, 91},
+		"arc distance":        {91.1},
+		"feed rate mode":      {93, 94},
+		"units":               {20, 21},
+		"cutter compensation": {40},
+		"tool length offset":  {43.1, 49},
+		"coordinate system":   {54, 55, 56, 57, 58, 59},
+		"control mode":        {61},
+	}
+	mGroups = map[string][]float64{
+		"stopping": {0, 1, 2, 30},
+		"spindle":  {3, 4, 5},
+		"coolant":  {7, 8, 9},
+	}
+)
+
+func lookupGroup(groups map[string][]float64, v float64) (string, bool) {
+	for group, values := range groups {
+		for _, value := range values {
+			if v == value {
+				return group, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkLine returns the error grbl would report for a line, or 0.
+func checkLine(l gcode.Line) uint8 {
+	if len(l.String()) > maxLineLength {
+		return ErrorOverflow
+	}
+
+	usedGroups := map[string]bool{}
+	words := map[rune]bool{}
+
+	for _, f := range l {
+		if f.IsComment() {
+			continue
+		}
+
+		var groups map[string][]float64
+		switch f.Letter {
+		case 'G':
+			groups = gGroups
+		case 'M':
+			groups = mGroups
+		default:
+			if !strings.ContainsRune(supportedWords, f.Letter) {
+				return ErrorGcodeUnsupportedCommand
+			}
+			if words[f.Letter] {
+				return ErrorGcodeWordRepeated
+			}
+			words[f.Letter] = true
+			continue
+		}
+
+		group, ok := lookupGroup(groups, f.Value)
+		if !ok {
+			return ErrorGcodeUnsupportedCommand
+		}
+		group = string(f.Letter) + group
+		if usedGroups[group] {
+			return ErrorGcodeModalGroupViolation
+		}
+		usedGroups[group] = true
+	}
+
+	return 0
+}
+
+// Preflight checks the lines read from src for commands that grbl would
+// refuse (unsupported commands, lines too long, repeated words), before
+// sending them, and returns all the errors found.
+func Preflight(src gcode.Source) ([]*LineError, error) {
+	rv := []*LineError{}
+
+	for i := 0; ; i++ {
+		l, err := src.Next()
+		if err == io.EOF {
+			return rv, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// tool changes are handled by the sender
+		l = stripToolChange(l)
+		if l.IsEmpty() {
+			continue
+		}
+
+		if code := checkLine(l); code != 0 {
+			rv = append(rv, &LineError{
+				Index: i,
+				Line:  l,
+				Err:   NewError(code),
+			})
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package grbl
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readLoop reads every line sent by grbl, forwarding acknowledgements
+// (ok/error:N) to the sender and dispatching everything else to the
+// response handlers.
+func (g *Grbl) readLoop() {
+	defer close(g.done)
+
+	for {
+		line, err := g.t.ReadLine()
+		if err != nil {
+			g.err = err
+			return
+		}
+
+		if line == "" {
+			continue
+		} else if line == "ok" {
+			g.acks <- nil
+			continue
+		} else if strings.HasPrefix(line, "error:") {
+			id, _ := strconv.ParseUint(line[6:], 10, 8)
+			g.acks <- NewError(uint8(id))
+			continue
+		}
+
+		if handler := g.handlers.Lookup(line); handler != nil {
+			g.Lock()
+			err := handler.Handle(line)
+			g.Unlock()
+
+			if err != nil {
+				log.Printf("error: grbl: %s", err)
+			}
+		} else {
+			log.Printf("warning: grbl: no handler found for response: %s", line)
+		}
+	}
+}
+
+// readAck waits for the next acknowledgement from grbl. grbl errors are
+// returned as ack, transport errors as err. if grbl is reset while waiting
+// ErrReset is returned, because the acknowledgement will never arrive.
+func (g *Grbl) readAck(reset <-chan struct{}) (ack error, err error) {
+	select {
+	case a := <-g.acks:
+		return a, nil
+	case <-reset:
+		return nil, ErrReset
+	case <-g.done:
+		return nil, g.err
+	}
+}
+
+func (g *Grbl) resetChan() <-chan struct{} {
+	g.RLock()
+	defer g.RUnlock()
+
+	return g.reset
+}
+
+// pollStatus requests a status report from grbl periodically, so the
+// machine state is kept up to date even during long running commands.
+func (g *Grbl) pollStatus(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+
+		case <-g.quit:
+			return
+
+		case d := <-g.interval:
+			ticker.Reset(d)
+
+		case <-ticker.C:
+			if err := g.t.WriteRealtime('?'); err != nil {
+				log.Printf("error: grbl: status: %s", err)
+			}
+		}
+	}
+}
+
+// SetStatusInterval changes the interval between status report requests.
+func (g *Grbl) SetStatusInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultStatusInterval
+	}
+
+	g.Lock()
+	g.statusEvery = d
+	g.Unlock()
+
+	select {
+	case g.interval <- d:
+	case <-g.done:
+	case <-g.quit:
+	}
+}
+
+// StatusInterval returns the interval between status report requests.
+func (g *Grbl) StatusInterval() time.Duration {
+	g.RLock()
+	defer g.RUnlock()
+
+	return g.statusEvery
+}
+
+// notifyStatus wakes up everyone waiting for a status report. must be
+// called with the lock held.
+func (g *Grbl) notifyStatus() {
+	for _, ch := range g.statusWaiters {
+		close(ch)
+	}
+	g.statusWaiters = nil
+}
+
+// RefreshStatus requests a status report from grbl and waits for it.
+func (g *Grbl) RefreshStatus(ctx context.Context) error {
+	return g.waitStatus(ctx, true)
+}
+
+// WaitStatus waits for the next status report from grbl, without
+// requesting it.
+func (g *Grbl) WaitStatus(ctx context.Context) error {
+	return g.waitStatus(ctx, false)
+}
+
+func (g *Grbl) waitStatus(ctx context.Context, request bool) error {
+	ch := make(chan struct{})
+
+	g.Lock()
+	g.statusWaiters = append(g.statusWaiters, ch)
+	g.Unlock()
+
+	if request {
+		if err := g.t.WriteRealtime('?'); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.done:
+		return g.err
+	}
+}
@@ -0,0 +1,186 @@
+package grbl
+
+import (
+	"context"
+	"fmt"
+)
+
+// realtime commands, handled by grbl as soon as they are received.
+const (
+	RTStatusReport = '?'
+	RTCycleStart   = '~'
+	RTFeedHold     = '!'
+	RTSoftReset    = 0x18
+	RTSafetyDoor   = 0x84
+	RTJogCancel    = 0x85
+
+	RTFeedOverrideReset       = 0x90
+	RTFeedOverrideCoarsePlus  = 0x91
+	RTFeedOverrideCoarseMinus = 0x92
+	RTFeedOverrideFinePlus    = 0x93
+	RTFeedOverrideFineMinus   = 0x94
+
+	RTRapidOverrideReset  = 0x95
+	RTRapidOverrideMedium = 0x96
+	RTRapidOverrideLow    = 0x97
+
+	RTSpindleOverrideReset       = 0x99
+	RTSpindleOverrideCoarsePlus  = 0x9A
+	RTSpindleOverrideCoarseMinus = 0x9B
+	RTSpindleOverrideFinePlus    = 0x9C
+	RTSpindleOverrideFineMinus   = 0x9D
+)
+
+// SendRTCommand writes a realtime command to grbl. realtime commands are
+// not acknowledged, their results are only visible in the status reports.
+func (g *Grbl) SendRTCommand(cmd byte) error {
+	return g.t.WriteRealtime(cmd)
+}
+
+// FeedHold stops the motion and pauses the job being sent, until
+// CycleStart is called.
+func (g *Grbl) FeedHold() error {
+	if err := g.SendRTCommand(RTFeedHold); err != nil {
+		return err
+	}
+
+	g.pause()
+	return nil
+}
+
+// SafetyDoor behaves like FeedHold, but also retracts and stops the spindle
+// (if parking is enabled in grbl).
+func (g *Grbl) SafetyDoor() error {
+	if err := g.SendRTCommand(RTSafetyDoor); err != nil {
+		return err
+	}
+
+	g.pause()
+	return nil
+}
+
+// CycleStart resumes the motion and the job being sent, after a FeedHold or
+// a SafetyDoor.
+func (g *Grbl) CycleStart() error {
+	if err := g.SendRTCommand(RTCycleStart); err != nil {
+		return err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	g.resume()
+	return nil
+}
+
+func (g *Grbl) JogCancel() error {
+	return g.SendRTCommand(RTJogCancel)
+}
+
+// SoftReset resets grbl, aborting any running job. pending lines fail with
+// ErrReset.
+func (g *Grbl) SoftReset() error {
+	return g.SendRTCommand(RTSoftReset)
+}
+
+func (g *Grbl) pause() {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.paused == nil {
+		g.paused = make(chan struct{})
+	}
+}
+
+// resume must be called with the lock held.
+func (g *Grbl) resume() {
+	if g.paused != nil {
+		close(g.paused)
+		g.paused = nil
+	}
+}
+
+// waitResume blocks while the job is paused.
+func (g *Grbl) waitResume(ctx context.Context) error {
+	g.RLock()
+	paused := g.paused
+	g.RUnlock()
+
+	if paused == nil {
+		return nil
+	}
+
+	select {
+	case <-paused:
+	case <-ctx.Done():
+	case <-g.done:
+		return g.err
+	}
+	return nil
+}
+
+// sendOverride changes an override by delta percent, using as many coarse
+// (10%) and fine (1%) steps as needed. the first command of cmds is the
+// coarse plus, followed by coarse minus, fine plus and fine minus.
+func (g *Grbl) sendOverride(delta int, cmds [4]byte) error {
+	for delta != 0 {
+		var cmd byte
+		switch {
+		case delta >= 10:
+			cmd, delta = cmds[0], delta-10
+		case delta <= -10:
+			cmd, delta = cmds[1], delta+10
+		case delta > 0:
+			cmd, delta = cmds[2], delta-1
+		default:
+			cmd, delta = cmds[3], delta+1
+		}
+
+		if err := g.SendRTCommand(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FeedOverride changes the feed rate override by delta percent.
+func (g *Grbl) FeedOverride(delta int) error {
+	return g.sendOverride(delta, [4]byte{
+		RTFeedOverrideCoarsePlus,
+		RTFeedOverrideCoarseMinus,
+		RTFeedOverrideFinePlus,
+		RTFeedOverrideFineMinus,
+	})
+}
+
+func (g *Grbl) FeedOverrideReset() error {
+	return g.SendRTCommand(RTFeedOverrideReset)
+}
+
+// RapidOverride sets the rapid override. grbl only supports 100%, 50% and
+// 25%.
+func (g *Grbl) RapidOverride(percent int) error {
+	switch percent {
+	case 100:
+		return g.SendRTCommand(RTRapidOverrideReset)
+	case 50:
+		return g.SendRTCommand(RTRapidOverrideMedium)
+	case 25:
+		return g.SendRTCommand(RTRapidOverrideLow)
+	}
+	return fmt.Errorf("grbl: invalid rapid override: %d%%", percent)
+}
+
+// SpindleOverride changes the spindle speed override by delta percent.
+func (g *Grbl) SpindleOverride(delta int) error {
+	return g.sendOverride(delta, [4]byte{
+		RTSpindleOverrideCoarsePlus,
+		RTSpindleOverrideCoarseMinus,
+		RTSpindleOverrideFinePlus,
+		RTSpindleOverrideFineMinus,
+	})
+}
+
+func (g *Grbl) SpindleOverrideReset() error {
+	return g.SendRTCommand(RTSpindleOverrideReset)
+}
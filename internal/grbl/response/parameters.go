@@ -0,0 +1,68 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+// Parameter is an offset reported by grbl ($#), except probe results that
+// are handled as messages.
+type Parameter struct {
+	Name     string       // G54-G59, G28, G30, G92 or TLO
+	Position *point.Point // nil for TLO
+	Value    float64      // only for TLO
+}
+
+type ParameterHandler struct {
+	Callback func(param *Parameter) error
+}
+
+var parameterNames = []string{"G54", "G55", "G56", "G57", "G58", "G59", "G28", "G30", "G92", "TLO"}
+
+func (*ParameterHandler) Supports(data string) bool {
+	if data[0] != '[' || data[len(data)-1] != ']' {
+		return false
+	}
+
+	for _, name := range parameterNames {
+		if strings.HasPrefix(data[1:], name+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ParameterHandler) Handle(data string) error {
+	parts := strings.SplitN(data[1:len(data)-1], ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("parameter: invalid message: %s", data)
+	}
+
+	if h.Callback == nil {
+		return errors.New("parameter: no callback defined")
+	}
+
+	param := &Parameter{
+		Name: parts[0],
+	}
+
+	if param.Name == "TLO" {
+		v, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return err
+		}
+		param.Value = v
+	} else {
+		p, err := point.NewFromStringMM(parts[1])
+		if err != nil {
+			return err
+		}
+		param.Position = p
+	}
+
+	return h.Callback(param)
+}
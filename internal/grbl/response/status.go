@@ -3,6 +3,7 @@ package response
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
@@ -36,13 +37,168 @@ var (
 	}
 )
 
+type Overrides struct {
+	Feed    uint8
+	Rapid   uint8
+	Spindle uint8
+}
+
+func NewOverridesFromString(str string) (*Overrides, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("status: invalid overrides: %s", str)
+	}
+
+	rv := make([]uint8, 3)
+	for i, part := range parts {
+		v, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		rv[i] = uint8(v)
+	}
+
+	return &Overrides{
+		Feed:    rv[0],
+		Rapid:   rv[1],
+		Spindle: rv[2],
+	}, nil
+}
+
+func (o *Overrides) String() string {
+	return fmt.Sprintf("F=%d%%,R=%d%%,S=%d%%", o.Feed, o.Rapid, o.Spindle)
+}
+
+// sub-states of StateHold
+const (
+	SubStateHoldComplete   = 0
+	SubStateHoldInProgress = 1
+)
+
+// sub-states of StateDoor
+const (
+	SubStateDoorReady     = 0
+	SubStateDoorStopped   = 1
+	SubStateDoorParking   = 2
+	SubStateDoorRestoring = 3
+)
+
+var (
+	subStateMax = map[StateType]int{
+		StateHold: SubStateHoldInProgress,
+		StateDoor: SubStateDoorRestoring,
+	}
+)
+
+type Buffer struct {
+	PlannerBlocks int
+	RXBytes       int
+}
+
+func NewBufferFromString(str string) (*Buffer, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("status: invalid buffer state: %s", str)
+	}
+
+	blocks, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Buffer{
+		PlannerBlocks: blocks,
+		RXBytes:       bytes,
+	}, nil
+}
+
+type Pins struct {
+	X          bool
+	Y          bool
+	Z          bool
+	Probe      bool
+	Door       bool
+	Hold       bool
+	SoftReset  bool
+	CycleStart bool
+}
+
+func NewPinsFromString(str string) (Pins, error) {
+	rv := Pins{}
+	for _, c := range str {
+		switch c {
+		case 'X':
+			rv.X = true
+		case 'Y':
+			rv.Y = true
+		case 'Z':
+			rv.Z = true
+		case 'P':
+			rv.Probe = true
+		case 'D':
+			rv.Door = true
+		case 'H':
+			rv.Hold = true
+		case 'R':
+			rv.SoftReset = true
+		case 'S':
+			rv.CycleStart = true
+		default:
+			return Pins{}, fmt.Errorf("status: invalid pin: %c", c)
+		}
+	}
+	return rv, nil
+}
+
+type Accessories struct {
+	SpindleCW  bool
+	SpindleCCW bool
+	Flood      bool
+	Mist       bool
+}
+
+func NewAccessoriesFromString(str string) (*Accessories, error) {
+	rv := &Accessories{}
+	for _, c := range str {
+		switch c {
+		case 'S':
+			rv.SpindleCW = true
+		case 'C':
+			rv.SpindleCCW = true
+		case 'F':
+			rv.Flood = true
+		case 'M':
+			rv.Mist = true
+		default:
+			return nil, fmt.Errorf("status: invalid accessory: %c", c)
+		}
+	}
+	return rv, nil
+}
+
 type Status struct {
 	State     StateType
+	SubState  int
 	StateName string
 	WCO       *point.Point
 	MPos      *point.Point
 	WPos      *point.Point
-	Other     map[string]string
+	Buffer    *Buffer
+	Line      *int
+	FeedRate  float64
+	Spindle   float64
+	Pins      Pins
+	Overrides *Overrides
+
+	// accessories state is only reported together with the overrides
+	Accessories *Accessories
+
+	Other map[string]string
 }
 
 type StatusHandler struct {
@@ -62,7 +218,6 @@ func (h *StatusHandler) Handle(data string) error {
 	}
 
 	stateParts := strings.Split(fields[0], ":")
-	// FIXME: handle sub-states
 
 	state, ok := stateMap[stateParts[0]]
 	if !ok {
@@ -71,10 +226,22 @@ func (h *StatusHandler) Handle(data string) error {
 
 	rv := &Status{
 		State:     state,
+		SubState:  -1,
 		StateName: fields[0],
 		Other:     map[string]string{},
 	}
 
+	if len(stateParts) > 1 {
+		sub, err := strconv.Atoi(stateParts[1])
+		if err != nil {
+			return fmt.Errorf("status: invalid sub-state: %s", fields[0])
+		}
+		if max, ok := subStateMax[state]; !ok || sub < 0 || sub > max {
+			return fmt.Errorf("status: invalid sub-state: %s", fields[0])
+		}
+		rv.SubState = sub
+	}
+
 	if err := func() error {
 		for _, field := range fields[1:] {
 			parts := strings.Split(field, ":")
@@ -112,11 +279,66 @@ func (h *StatusHandler) Handle(data string) error {
 
 				defer func() {
 					if h.wco != nil {
-						rv.MPos = rv.MPos.Add(h.wco)
+						rv.MPos = rv.WPos.Add(h.wco)
 						rv.WCO = h.wco.Copy()
 					}
 				}()
 
+			case "Bf":
+				rv.Buffer, err = NewBufferFromString(parts[1])
+				if err != nil {
+					return err
+				}
+
+			case "Ln":
+				ln, err := strconv.Atoi(parts[1])
+				if err != nil {
+					return err
+				}
+				rv.Line = &ln
+
+			case "F":
+				rv.FeedRate, err = strconv.ParseFloat(parts[1], 64)
+				if err != nil {
+					return err
+				}
+
+			case "FS":
+				fs := strings.Split(parts[1], ",")
+				if len(fs) != 2 {
+					return fmt.Errorf("status: invalid feed/spindle: %s", parts[1])
+				}
+				rv.FeedRate, err = strconv.ParseFloat(fs[0], 64)
+				if err != nil {
+					return err
+				}
+				rv.Spindle, err = strconv.ParseFloat(fs[1], 64)
+				if err != nil {
+					return err
+				}
+
+			case "Pn":
+				rv.Pins, err = NewPinsFromString(parts[1])
+				if err != nil {
+					return err
+				}
+
+			case "Ov":
+				rv.Overrides, err = NewOverridesFromString(parts[1])
+				if err != nil {
+					return err
+				}
+
+				if rv.Accessories == nil {
+					rv.Accessories = &Accessories{}
+				}
+
+			case "A":
+				rv.Accessories, err = NewAccessoriesFromString(parts[1])
+				if err != nil {
+					return err
+				}
+
 			default:
 				rv.Other[parts[0]] = strings.TrimSpace(parts[1])
 			}
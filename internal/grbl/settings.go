@@ -0,0 +1,31 @@
+package grbl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/settings"
+)
+
+// RefreshSettings requests all the settings from grbl.
+func (g *Grbl) RefreshSettings(ctx context.Context) error {
+	return g.SendCommands(ctx, "$$")
+}
+
+// SetSetting writes a setting to grbl's EEPROM.
+func (g *Grbl) SetSetting(ctx context.Context, key uint8, value float64) error {
+	if err := settings.Validate(key, value); err != nil {
+		return err
+	}
+
+	if err := g.SendCommands(ctx, fmt.Sprintf("$%d=%s", key, settings.Format(key, value))); err != nil {
+		return fmt.Errorf("grbl: failed to set $%d: %w", key, err)
+	}
+
+	// grbl doesn't report settings when they change
+	g.Lock()
+	g.Settings[key] = value
+	g.Unlock()
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package settings
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Info describes a grbl 1.1 setting.
+type Info struct {
+	Key         uint8
+	Name        string
+	Unit        string
+	Description string
+
+	// Integer settings are stored by grbl as integers. booleans and masks
+	// are integers too.
+	Integer bool
+	Boolean bool
+
+	// Bits are the names of the bits of mask settings, from the least
+	// significant one.
+	Bits []string
+}
+
+var axisBits = []string{"X", "Y", "Z"}
+
+var catalogue = []*Info{
+	{Key: 0, Name: "Step pulse time", Unit: "microseconds", Integer: true,
+		Description: "Length of the step pulse sent to the stepper drivers."},
+	{Key: 1, Name: "Step idle delay", Unit: "milliseconds", Integer: true,
+		Description: "Time the steppers are kept enabled after a motion, 255 keeps them always enabled."},
+	{Key: 2, Name: "Step pulse invert", Unit: "mask", Integer: true, Bits: axisBits,
+		Description: "Inverts the step pulse signal of the axes."},
+	{Key: 3, Name: "Step direction invert", Unit: "mask", Integer: true, Bits: axisBits,
+		Description: "Inverts the direction signal of the axes."},
+	{Key: 4, Name: "Invert step enable pin", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Inverts the stepper enable pin signal."},
+	{Key: 5, Name: "Invert limit pins", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Inverts the limit switch pins, for normally open switches without pull-up."},
+	{Key: 6, Name: "Invert probe pin", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Inverts the probe pin, for normally open probes without pull-up."},
+	{Key: 10, Name: "Status report options", Unit: "mask", Integer: true, Bits: []string{"MPos", "Buffer"},
+		Description: "Data included in status reports: machine position instead of work position, and buffer state."},
+	{Key: 11, Name: "Junction deviation", Unit: "mm",
+		Description: "How fast the machine moves through the junctions of consecutive motions."},
+	{Key: 12, Name: "Arc tolerance", Unit: "mm",
+		Description: "Maximum distance between arcs and the segments that replace them."},
+	{Key: 13, Name: "Report in inches", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Reports positions in inches instead of mm."},
+	{Key: 20, Name: "Soft limits enable", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Rejects motions beyond the maximum travel. requires homing."},
+	{Key: 21, Name: "Hard limits enable", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Stops the machine when a limit switch is triggered."},
+	{Key: 22, Name: "Homing cycle enable", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Enables the homing cycle ($H), required after reset."},
+	{Key: 23, Name: "Homing direction invert", Unit: "mask", Integer: true, Bits: axisBits,
+		Description: "Homes the axes towards the negative direction."},
+	{Key: 24, Name: "Homing locate feed rate", Unit: "mm/min",
+		Description: "Feed rate used to precisely locate the limit switches."},
+	{Key: 25, Name: "Homing search seek rate", Unit: "mm/min",
+		Description: "Feed rate used to search for the limit switches."},
+	{Key: 26, Name: "Homing switch debounce delay", Unit: "milliseconds", Integer: true,
+		Description: "Delay to debounce the limit switches during homing."},
+	{Key: 27, Name: "Homing switch pull-off distance", Unit: "mm",
+		Description: "Distance moved away from the limit switches after homing."},
+	{Key: 30, Name: "Maximum spindle speed", Unit: "RPM", Integer: true,
+		Description: "Spindle speed for the maximum PWM output."},
+	{Key: 31, Name: "Minimum spindle speed", Unit: "RPM", Integer: true,
+		Description: "Spindle speed for the minimum PWM output."},
+	{Key: 32, Name: "Laser-mode enable", Unit: "boolean", Integer: true, Boolean: true,
+		Description: "Enables laser mode, that doesn't stop motions to change the spindle speed."},
+	{Key: 100, Name: "X-axis travel resolution", Unit: "step/mm",
+		Description: "Steps per mm of the X axis."},
+	{Key: 101, Name: "Y-axis travel resolution", Unit: "step/mm",
+		Description: "Steps per mm of the Y axis."},
+	{Key: 102, Name: "Z-axis travel resolution", Unit: "step/mm",
+		Description: "Steps per mm of the Z axis."},
+	{Key: 110, Name: "X-axis maximum rate", Unit: "mm/min",
+		Description: "Maximum speed of the X axis."},
+	{Key: 111, Name: "Y-axis maximum rate", Unit: "mm/min",
+		Description: "Maximum speed of the Y axis."},
+	{Key: 112, Name: "Z-axis maximum rate", Unit: "mm/min",
+		Description: "Maximum speed of the Z axis."},
+	{Key: 120, Name: "X-axis acceleration", Unit: "mm/sec^2",
+		Description: "Acceleration of the X axis."},
+	{Key: 121, Name: "Y-axis acceleration", Unit: "mm/sec^2",
+		Description: "Acceleration of the Y axis."},
+	{Key: 122, Name: "Z-axis acceleration", Unit: "mm/sec^2",
+		Description: "Acceleration of the Z axis."},
+	{Key: 130, Name: "X-axis maximum travel", Unit: "mm",
+		Description: "Maximum travel of the X axis from home, used by soft limits."},
+	{Key: 131, Name: "Y-axis maximum travel", Unit: "mm",
+		Description: "Maximum travel of the Y axis from home, used by soft limits."},
+	{Key: 132, Name: "Z-axis maximum travel", Unit: "mm",
+		Description: "Maximum travel of the Z axis from home, used by soft limits."},
+}
+
+// Catalogue returns the description of all the grbl 1.1 settings, sorted
+// by key.
+func Catalogue() []*Info {
+	return catalogue
+}
+
+// Lookup returns the description of a setting, or nil if it is unknown.
+func Lookup(key uint8) *Info {
+	for _, info := range catalogue {
+		if info.Key == key {
+			return info
+		}
+	}
+	return nil
+}
+
+// Format formats a value the same way grbl does.
+func Format(key uint8, value float64) string {
+	if info := Lookup(key); info != nil && info.Integer {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3f", value)
+}
+
+// Equal returns true if two values of a setting are the same, as stored
+// by grbl.
+func Equal(key uint8, a float64, b float64) bool {
+	return Format(key, a) == Format(key, b)
+}
+
+// Decode returns a human readable representation of the value, for
+// booleans and masks.
+func Decode(key uint8, value float64) string {
+	info := Lookup(key)
+	if info == nil {
+		return ""
+	}
+
+	if info.Boolean {
+		if value != 0 {
+			return "enabled"
+		}
+		return "disabled"
+	}
+
+	if info.Bits != nil {
+		v := int(value)
+		rv := []string{}
+		for i, bit := range info.Bits {
+			if v&(1<<i) != 0 {
+				rv = append(rv, bit)
+			}
+		}
+		if len(rv) == 0 {
+			return "none"
+		}
+		return strings.Join(rv, ", ")
+	}
+
+	return ""
+}
+
+// Validate checks if a value is acceptable for a setting, before sending
+// it to grbl.
+func Validate(key uint8, value float64) error {
+	info := Lookup(key)
+	if info == nil {
+		return fmt.Errorf("settings: unknown setting: $%d", key)
+	}
+
+	if value < 0 {
+		return fmt.Errorf("settings: negative value for $%d: %s", key, Format(key, value))
+	}
+	if info.Integer && value != float64(int(value)) {
+		return fmt.Errorf("settings: integer value required for $%d: %f", key, value)
+	}
+	if info.Boolean && value != 0 && value != 1 {
+		return fmt.Errorf("settings: boolean value required for $%d: %s", key, Format(key, value))
+	}
+	if info.Bits != nil && int(value) >= 1<<len(info.Bits) {
+		return fmt.Errorf("settings: invalid mask for $%d: %s", key, Format(key, value))
+	}
+	return nil
+}
+
+// Keys returns the keys of a settings map, sorted.
+func Keys(s map[uint8]float64) []uint8 {
+	rv := make([]uint8, 0, len(s))
+	for k := range s {
+		rv = append(rv, k)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i] < rv[j]
+	})
+	return rv
+}
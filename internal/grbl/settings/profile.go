@@ -0,0 +1,115 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ProfileVersion is the version of the profile file format.
+const ProfileVersion = 1
+
+// Profile is a backup of the settings of a controller.
+type Profile struct {
+	Version  int               `json:"version"`
+	Firmware string            `json:"firmware,omitempty"`
+	Created  time.Time         `json:"created"`
+	Settings map[uint8]float64 `json:"settings"`
+}
+
+func NewProfile(firmware string, s map[uint8]float64) *Profile {
+	rv := &Profile{
+		Version:  ProfileVersion,
+		Firmware: firmware,
+		Created:  time.Now().UTC(),
+		Settings: map[uint8]float64{},
+	}
+	for k, v := range s {
+		rv.Settings[k] = v
+	}
+	return rv
+}
+
+func LoadProfile(fname string) (*Profile, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &Profile{}
+	if err := json.Unmarshal(data, rv); err != nil {
+		return nil, err
+	}
+
+	if rv.Version != ProfileVersion {
+		return nil, fmt.Errorf("settings: unsupported profile version: %d", rv.Version)
+	}
+	if len(rv.Settings) == 0 {
+		return nil, fmt.Errorf("settings: no settings in profile: %s", fname)
+	}
+	for _, k := range Keys(rv.Settings) {
+		if err := Validate(k, rv.Settings[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	return rv, nil
+}
+
+func (p *Profile) Save(fname string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fname, append(data, '\n'), 0666)
+}
+
+// Change is a difference between two sets of settings. From or To are
+// nil if the setting is missing on the respective side.
+type Change struct {
+	Key  uint8
+	From *float64
+	To   *float64
+}
+
+func (c *Change) String() string {
+	f := func(v *float64) string {
+		if v == nil {
+			return "(missing)"
+		}
+		return Format(c.Key, *v)
+	}
+	return fmt.Sprintf("$%d: %s -> %s", c.Key, f(c.From), f(c.To))
+}
+
+// Diff returns the changes needed to turn the settings from into to.
+func Diff(from map[uint8]float64, to map[uint8]float64) []*Change {
+	keys := map[uint8]float64{}
+	for k := range from {
+		keys[k] = 0
+	}
+	for k := range to {
+		keys[k] = 0
+	}
+
+	rv := []*Change{}
+	for _, k := range Keys(keys) {
+		f, fok := from[k]
+		t, tok := to[k]
+		if fok && tok && Equal(k, f, t) {
+			continue
+		}
+
+		c := &Change{Key: k}
+		if fok {
+			c.From = &f
+		}
+		if tok {
+			c.To = &t
+		}
+		rv = append(rv, c)
+	}
+	return rv
+}
@@ -0,0 +1,904 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	statusOK      = 0
+	statusAborted = -1
+
+	statusExpectedCommandLetter       = 1
+	statusBadNumberFormat             = 2
+	statusInvalidStatement            = 3
+	statusNegativeValue               = 4
+	statusSettingDisabled             = 5
+	statusIdleError                   = 8
+	statusSystemGcLock                = 9
+	statusOverflow                    = 11
+	statusTravelExceeded              = 15
+	statusInvalidJogCommand           = 16
+	statusGcodeUnsupportedCommand     = 20
+	statusGcodeModalGroupViolation    = 21
+	statusGcodeUndefinedFeedRate      = 22
+	statusGcodeCommandValueNotInteger = 23
+	statusGcodeAxisCommandConflict    = 24
+	statusGcodeWordRepeated           = 25
+	statusGcodeNoAxisWords            = 26
+	statusGcodeInvalidLineNumber      = 27
+	statusGcodeValueWordMissing       = 28
+	statusGcodeUnsupportedCoordSys    = 29
+	statusGcodeG53InvalidMotionMode   = 30
+	statusGcodeAxisWordsExist         = 31
+	statusGcodeNoAxisWordsInPlane     = 32
+	statusGcodeInvalidTarget          = 33
+	statusGcodeArcRadiusError         = 34
+	statusGcodeNoOffsetsInPlane       = 35
+	statusGcodeUnusedWords            = 36
+	statusGcodeMaxValueExceeded       = 38
+)
+
+func statusString(st int) string {
+	if st == statusOK {
+		return "ok"
+	}
+	return fmt.Sprintf("error:%d", st)
+}
+
+// modal groups, as defined by grbl's gcode parser
+const (
+	groupNonModal = iota
+	groupMotion
+	groupPlane
+	groupDistance
+	groupArcDistance
+	groupFeedMode
+	groupUnits
+	groupCutterComp
+	groupToolLength
+	groupCoord
+	groupControl
+	groupStopping
+	groupSpindle
+	groupCoolant
+	groupToolChange
+	groupOverride
+)
+
+var (
+	// g-codes are stored multiplied by 10, to handle G38.2 and friends.
+	gGroups = map[int]int{
+		0:   groupMotion,
+		10:  groupMotion,
+		20:  groupMotion,
+		30:  groupMotion,
+		382: groupMotion,
+		383: groupMotion,
+		384: groupMotion,
+		385: groupMotion,
+		800: groupMotion,
+		40:  groupNonModal,
+		100: groupNonModal,
+		280: groupNonModal,
+		281: groupNonModal,
+		300: groupNonModal,
+		301: groupNonModal,
+		530: groupNonModal,
+		920: groupNonModal,
+		921: groupNonModal,
+		170: groupPlane,
+		180: groupPlane,
+		190: groupPlane,
+		900: groupDistance,
+		910: groupDistance,
+		911: groupArcDistance,
+		930: groupFeedMode,
+		940: groupFeedMode,
+		200: groupUnits,
+		210: groupUnits,
+		400: groupCutterComp,
+		431: groupToolLength,
+		490: groupToolLength,
+		540: groupCoord,
+		550: groupCoord,
+		560: groupCoord,
+		570: groupCoord,
+		580: groupCoord,
+		590: groupCoord,
+		610: groupControl,
+	}
+
+	mGroups = map[int]int{
+		0:  groupStopping,
+		1:  groupStopping,
+		2:  groupStopping,
+		30: groupStopping,
+		3:  groupSpindle,
+		4:  groupSpindle,
+		5:  groupSpindle,
+		6:  groupToolChange,
+		7:  groupCoolant,
+		8:  groupCoolant,
+		9:  groupCoolant,
+		56: groupOverride,
+	}
+)
+
+type modal struct {
+	motion   int
+	plane    int
+	units    int
+	distance int
+	feedMode int
+	coord    int
+	spindle  int
+	flood    bool
+	mist     bool
+	tool     int
+	feed     float64
+	speed    float64
+
+	// parser position, in machine coordinates
+	position [3]float64
+}
+
+func newModal() *modal {
+	return &modal{
+		motion:   0,
+		plane:    170,
+		units:    210,
+		distance: 900,
+		feedMode: 940,
+		coord:    0,
+		spindle:  5,
+	}
+}
+
+func (m *modal) String() string {
+	motion := fmt.Sprintf("G%d", m.motion/10)
+	if m.motion%10 != 0 {
+		motion = fmt.Sprintf("G%d.%d", m.motion/10, m.motion%10)
+	}
+
+	coolant := "M9"
+	if m.mist && m.flood {
+		coolant = "M7 M8"
+	} else if m.mist {
+		coolant = "M7"
+	} else if m.flood {
+		coolant = "M8"
+	}
+
+	feed := m.feed
+	if m.units == 200 {
+		feed /= 25.4
+	}
+
+	return fmt.Sprintf("%s G%d G%d G%d G%d G%d M%d %s T%d F%.0f S%.0f",
+		motion, m.coord+54, m.plane/10, m.units/10, m.distance/10,
+		m.feedMode/10, m.spindle, coolant, m.tool, feed, m.speed)
+}
+
+type word struct {
+	letter byte
+	value  float64
+}
+
+// preprocess cleans up a line like grbl's protocol layer does: removes
+// whitespaces and comments, and converts letters to upper case.
+func preprocess(line string) string {
+	rv := strings.Builder{}
+	comment := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		if comment {
+			if c == ')' {
+				comment = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '(':
+			comment = true
+		case c == ';':
+			return rv.String()
+		case c <= ' ':
+		case c >= 'a' && c <= 'z':
+			rv.WriteByte(c - 'a' + 'A')
+		default:
+			rv.WriteByte(c)
+		}
+	}
+
+	return rv.String()
+}
+
+func parseWords(line string) ([]word, int) {
+	rv := []word{}
+
+	for i := 0; i < len(line); {
+		letter := line[i]
+		if letter < 'A' || letter > 'Z' {
+			return nil, statusExpectedCommandLetter
+		}
+		i++
+
+		start := i
+		if i < len(line) && (line[i] == '-' || line[i] == '+') {
+			i++
+		}
+		digits := 0
+		dot := false
+		for ; i < len(line); i++ {
+			if line[i] >= '0' && line[i] <= '9' {
+				digits++
+			} else if line[i] == '.' && !dot {
+				dot = true
+			} else {
+				break
+			}
+		}
+		if digits == 0 {
+			return nil, statusBadNumberFormat
+		}
+
+		v, err := strconv.ParseFloat(line[start:i], 64)
+		if err != nil {
+			return nil, statusBadNumberFormat
+		}
+
+		rv = append(rv, word{
+			letter: letter,
+			value:  v,
+		})
+	}
+
+	return rv, statusOK
+}
+
+type gcodeBlock struct {
+	gcodes   map[int]int
+	mcodes   map[int]int
+	values   map[byte]float64
+	axis     [3]bool
+	hasAxis  bool
+	nonModal int
+}
+
+func parseBlock(line string, jog bool) (*gcodeBlock, int) {
+	words, st := parseWords(line)
+	if st != statusOK {
+		return nil, st
+	}
+
+	rv := &gcodeBlock{
+		gcodes:   map[int]int{},
+		mcodes:   map[int]int{},
+		values:   map[byte]float64{},
+		nonModal: -1,
+	}
+
+	for _, w := range words {
+		switch w.letter {
+		case 'G':
+			code := int(math.Round(w.value * 10))
+			if math.Abs(w.value*10-float64(code)) > 1e-6 {
+				return nil, statusGcodeUnsupportedCommand
+			}
+			group, ok := gGroups[code]
+			if !ok {
+				if code > 590 && code < 600 {
+					return nil, statusGcodeUnsupportedCoordSys
+				}
+				return nil, statusGcodeUnsupportedCommand
+			}
+			if jog && code != 200 && code != 210 && code != 900 && code != 910 && code != 530 {
+				return nil, statusInvalidJogCommand
+			}
+			if _, found := rv.gcodes[group]; found {
+				return nil, statusGcodeModalGroupViolation
+			}
+			rv.gcodes[group] = code
+			if group == groupNonModal {
+				rv.nonModal = code
+			}
+
+		case 'M':
+			if jog {
+				return nil, statusInvalidJogCommand
+			}
+			if w.value != math.Trunc(w.value) {
+				return nil, statusGcodeUnsupportedCommand
+			}
+			group, ok := mGroups[int(w.value)]
+			if !ok {
+				return nil, statusGcodeUnsupportedCommand
+			}
+			if _, found := rv.mcodes[group]; found {
+				return nil, statusGcodeModalGroupViolation
+			}
+			rv.mcodes[group] = int(w.value)
+
+		case 'F', 'I', 'J', 'K', 'L', 'N', 'P', 'R', 'S', 'T', 'X', 'Y', 'Z':
+			if jog && !strings.ContainsRune("FNXYZ", rune(w.letter)) {
+				return nil, statusInvalidJogCommand
+			}
+			if _, found := rv.values[w.letter]; found {
+				return nil, statusGcodeWordRepeated
+			}
+
+			switch w.letter {
+			case 'F', 'N', 'P', 'S', 'T':
+				if w.value < 0 {
+					return nil, statusNegativeValue
+				}
+			}
+
+			switch w.letter {
+			case 'L', 'N', 'T':
+				if w.value != math.Trunc(w.value) {
+					return nil, statusGcodeCommandValueNotInteger
+				}
+			}
+
+			if w.letter == 'N' && w.value > 9999999 {
+				return nil, statusGcodeInvalidLineNumber
+			}
+			if w.letter == 'T' && w.value > 255 {
+				return nil, statusGcodeMaxValueExceeded
+			}
+
+			if i := strings.IndexByte("XYZ", w.letter); i >= 0 {
+				rv.axis[i] = true
+				rv.hasAxis = true
+			}
+			rv.values[w.letter] = w.value
+
+		default:
+			return nil, statusGcodeUnsupportedCommand
+		}
+	}
+
+	return rv, statusOK
+}
+
+func (b *gcodeBlock) has(letter byte) bool {
+	_, found := b.values[letter]
+	return found
+}
+
+func (b *gcodeBlock) use(letter byte) (float64, bool) {
+	v, found := b.values[letter]
+	delete(b.values, letter)
+	return v, found
+}
+
+// executeGCode parses and executes a block of g-code, returning a grbl
+// status code. must be called with the lock held.
+func (s *Sim) executeGCode(line string, jog bool) int {
+	if s.state == stateAlarm || (s.state == stateJog && !jog) {
+		return statusSystemGcLock
+	}
+
+	line = preprocess(line)
+	if len(line) >= lineBufferSize {
+		return statusOverflow
+	}
+
+	b, st := parseBlock(line, jog)
+	if st != statusOK {
+		return st
+	}
+
+	// changes are made to a copy of the modal state, that is only
+	// committed if the whole block is valid, like grbl does.
+	gc := *s.gc
+	if jog {
+		gc.motion = 10
+	}
+
+	b.use('N')
+
+	if code, ok := b.gcodes[groupUnits]; ok {
+		gc.units = code
+	}
+	unit := 1.
+	if gc.units == 200 {
+		unit = 25.4
+	}
+
+	if code, ok := b.gcodes[groupFeedMode]; ok {
+		gc.feedMode = code
+	}
+
+	feed, hasFeed := b.use('F')
+	if hasFeed && gc.feedMode == 940 {
+		gc.feed = feed * unit
+	}
+	if jog && !hasFeed {
+		return statusGcodeUndefinedFeedRate
+	}
+
+	if v, ok := b.use('S'); ok {
+		gc.speed = v
+	}
+	if v, ok := b.use('T'); ok {
+		gc.tool = int(v)
+	}
+
+	if code, ok := b.mcodes[groupSpindle]; ok {
+		gc.spindle = code
+	}
+	if code, ok := b.mcodes[groupCoolant]; ok {
+		switch code {
+		case 7:
+			gc.mist = true
+		case 8:
+			gc.flood = true
+		case 9:
+			gc.mist = false
+			gc.flood = false
+		}
+	}
+
+	dwell := -1.
+	if b.nonModal == 40 {
+		p, ok := b.use('P')
+		if !ok {
+			return statusGcodeValueWordMissing
+		}
+		dwell = p
+	}
+
+	if code, ok := b.gcodes[groupPlane]; ok {
+		gc.plane = code
+	}
+	if code, ok := b.gcodes[groupCoord]; ok {
+		gc.coord = (code - 540) / 10
+	}
+	if code, ok := b.gcodes[groupDistance]; ok {
+		gc.distance = code
+	}
+
+	tlo := s.tlo
+	if code, ok := b.gcodes[groupToolLength]; ok {
+		if code == 490 {
+			tlo = 0
+		} else {
+			if !b.axis[2] {
+				return statusGcodeValueWordMissing
+			}
+			tlo = b.values['Z'] * unit
+			b.axis[2] = false
+			b.hasAxis = b.axis[0] || b.axis[1]
+			delete(b.values, 'Z')
+		}
+	}
+
+	motionWord, hasMotionWord := b.gcodes[groupMotion]
+	if hasMotionWord {
+		gc.motion = motionWord
+	}
+
+	axisNonModal := b.nonModal == 100 || b.nonModal == 280 || b.nonModal == 300 || b.nonModal == 920
+	if axisNonModal && hasMotionWord {
+		return statusGcodeAxisCommandConflict
+	}
+
+	g53 := b.nonModal == 530
+	if g53 && gc.motion != 0 && gc.motion != 10 {
+		return statusGcodeG53InvalidMotionMode
+	}
+
+	coords := s.coords
+	g28 := s.g28
+	g30 := s.g30
+	g92 := s.g92
+
+	wco := func(i int) float64 {
+		rv := coords[gc.coord][i] + g92[i]
+		if i == 2 {
+			rv += tlo
+		}
+		return rv
+	}
+
+	target := gc.position
+	for i, l := range []byte("XYZ") {
+		if !b.axis[i] {
+			continue
+		}
+		v := b.values[l] * unit
+		if axisNonModal && b.nonModal != 280 && b.nonModal != 300 {
+			continue
+		}
+		if g53 {
+			target[i] = v
+		} else if gc.distance == 900 {
+			target[i] = v + wco(i)
+		} else {
+			target[i] = gc.position[i] + v
+		}
+	}
+
+	moveTo := [][3]float64{}
+
+	switch b.nonModal {
+	case 100:
+		l, okL := b.use('L')
+		p, okP := b.use('P')
+		if !okL || !okP {
+			return statusGcodeValueWordMissing
+		}
+		if p > 6 || p != math.Trunc(p) {
+			return statusGcodeUnsupportedCoordSys
+		}
+		idx := int(p) - 1
+		if idx < 0 {
+			idx = gc.coord
+		}
+		for i, a := range []byte("XYZ") {
+			if !b.axis[i] {
+				continue
+			}
+			v := b.values[a] * unit
+			switch l {
+			case 2:
+				coords[idx][i] = v
+			case 20:
+				coords[idx][i] = gc.position[i] - g92[i] - v
+				if i == 2 {
+					coords[idx][i] -= tlo
+				}
+			default:
+				return statusGcodeUnsupportedCommand
+			}
+		}
+
+	case 280, 300:
+		stored := g28
+		if b.nonModal == 300 {
+			stored = g30
+		}
+		if b.hasAxis {
+			moveTo = append(moveTo, target)
+		}
+		moveTo = append(moveTo, stored)
+		target = stored
+
+	case 281:
+		g28 = s.mpos
+
+	case 301:
+		g30 = s.mpos
+
+	case 920:
+		for i, a := range []byte("XYZ") {
+			if b.axis[i] {
+				g92[i] = gc.position[i] - coords[gc.coord][i] - b.values[a]*unit
+				if i == 2 {
+					g92[i] -= tlo
+				}
+			}
+		}
+
+	case 921:
+		g92 = [3]float64{}
+	}
+
+	motion := -1
+	if !axisNonModal && (b.hasAxis || hasMotionWord) {
+		motion = gc.motion
+		if motion == 800 && b.hasAxis {
+			return statusGcodeAxisWordsExist
+		}
+	}
+
+	var arc [][3]float64
+	rate := gc.feed
+
+	switch motion {
+	case 10, 20, 30, 382, 383, 384, 385:
+		if gc.feedMode == 930 {
+			if !hasFeed {
+				return statusGcodeUndefinedFeedRate
+			}
+			rate = feed * unit
+		} else if rate == 0 {
+			return statusGcodeUndefinedFeedRate
+		}
+	}
+
+	switch motion {
+	case 20, 30:
+		var st int
+		arc, st = s.arc(b, &gc, target, unit)
+		if st != statusOK {
+			return st
+		}
+
+	case 382, 383, 384, 385:
+		if !b.hasAxis {
+			return statusGcodeNoAxisWords
+		}
+		if target == gc.position {
+			return statusGcodeInvalidTarget
+		}
+	}
+
+	if len(b.values) > 0 {
+		for l := range b.values {
+			if !strings.ContainsRune("XYZ", rune(l)) {
+				return statusGcodeUnusedWords
+			}
+		}
+	}
+
+	if gc.feedMode == 930 && motion >= 0 {
+		// inverse time feed rate: convert to mm/min, using the length
+		// of the whole move.
+		dist := distance(gc.position, target)
+		if len(arc) > 0 {
+			dist = 0
+			prev := gc.position
+			for _, p := range arc {
+				dist += distance(prev, p)
+				prev = p
+			}
+		}
+		rate *= dist
+	}
+
+	// soft limits
+	if s.setting(20) != 0 && motion >= 0 && motion != 800 {
+		if !s.withinLimits(target) {
+			if jog {
+				return statusTravelExceeded
+			}
+			// soft limit alarm is critical, no response is sent.
+			s.alarm(2)
+			return statusAborted
+		}
+	}
+
+	// everything is valid, commit the changes.
+	s.gc.units = gc.units
+	s.gc.feedMode = gc.feedMode
+	s.gc.feed = gc.feed
+	s.gc.speed = gc.speed
+	s.gc.tool = gc.tool
+	s.gc.spindle = gc.spindle
+	s.gc.mist = gc.mist
+	s.gc.flood = gc.flood
+	s.gc.plane = gc.plane
+	s.gc.coord = gc.coord
+	s.gc.distance = gc.distance
+	if !jog {
+		s.gc.motion = gc.motion
+	}
+	s.tlo = tlo
+	s.g28 = g28
+	s.g30 = g30
+
+	if s.coords != coords || s.g92 != g92 {
+		if s.state != stateCheck && !s.sync() {
+			return statusAborted
+		}
+		s.coords = coords
+		s.g92 = g92
+	}
+
+	check := s.state == stateCheck
+
+	if dwell >= 0 && !check {
+		if !s.sync() {
+			return statusAborted
+		}
+		s.plan(&block{
+			kind:     blockDwell,
+			duration: dwell / 60,
+		})
+		if !s.sync() {
+			return statusAborted
+		}
+	}
+
+	for _, p := range moveTo {
+		if !check && !s.plan(&block{target: p, rapid: true}) {
+			return statusAborted
+		}
+		s.gc.position = p
+	}
+
+	switch motion {
+	case 0, 10:
+		if !check && !s.plan(&block{target: target, rate: rate, rapid: motion == 0, jog: jog}) {
+			return statusAborted
+		}
+		s.gc.position = target
+
+	case 20, 30:
+		for _, p := range arc {
+			if !check && !s.plan(&block{target: p, rate: rate}) {
+				return statusAborted
+			}
+		}
+		s.gc.position = target
+
+	case 382, 383, 384, 385:
+		if check {
+			break
+		}
+		if !s.sync() {
+			return statusAborted
+		}
+		if st := s.runProbe(target, rate, motion); st != statusOK {
+			return st
+		}
+	}
+
+	if code, ok := b.mcodes[groupStopping]; ok && !check {
+		switch code {
+		case 0:
+			if !s.sync() {
+				return statusAborted
+			}
+			s.state = stateHold
+			s.subState = 0
+
+		case 2, 30:
+			if !s.sync() {
+				return statusAborted
+			}
+			s.gc.motion = 10
+			s.gc.plane = 170
+			s.gc.distance = 900
+			s.gc.feedMode = 940
+			s.gc.coord = 0
+			s.gc.spindle = 5
+			s.gc.mist = false
+			s.gc.flood = false
+			s.tlo = 0
+			s.sendMessage("Pgm End")
+		}
+	}
+
+	return statusOK
+}
+
+func distance(a [3]float64, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+func (s *Sim) withinLimits(target [3]float64) bool {
+	for i := 0; i < 3; i++ {
+		travel := s.setting(uint8(130 + i))
+		if int(s.setting(23))&(1<<i) != 0 {
+			if target[i] < 0 || target[i] > travel {
+				return false
+			}
+		} else {
+			if target[i] > 0 || target[i] < -travel {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// arc validates an arc move and splits it into linear segments, using
+// the same algorithm as grbl's mc_arc.
+func (s *Sim) arc(b *gcodeBlock, gc *modal, target [3]float64, unit float64) ([][3]float64, int) {
+	var a0, a1, linear int
+	var o0, o1 byte
+
+	switch gc.plane {
+	case 170:
+		a0, a1, linear = 0, 1, 2
+		o0, o1 = 'I', 'J'
+	case 180:
+		a0, a1, linear = 2, 0, 1
+		o0, o1 = 'K', 'I'
+	default:
+		a0, a1, linear = 1, 2, 0
+		o0, o1 = 'J', 'K'
+	}
+
+	if !b.hasAxis {
+		return nil, statusGcodeNoAxisWords
+	}
+
+	x := target[a0] - gc.position[a0]
+	y := target[a1] - gc.position[a1]
+
+	var i, j float64
+	if r, ok := b.use('R'); ok {
+		if !b.axis[a0] && !b.axis[a1] {
+			return nil, statusGcodeNoAxisWordsInPlane
+		}
+		if target == gc.position {
+			return nil, statusGcodeInvalidTarget
+		}
+
+		r *= unit
+		hx2 := 4*r*r - x*x - y*y
+		if hx2 < 0 {
+			return nil, statusGcodeArcRadiusError
+		}
+
+		h := -math.Sqrt(hx2) / math.Hypot(x, y)
+		if gc.motion == 30 {
+			h = -h
+		}
+		if r < 0 {
+			h = -h
+		}
+
+		i = 0.5 * (x - y*h)
+		j = 0.5 * (y + x*h)
+	} else {
+		v0, ok0 := b.use(o0)
+		v1, ok1 := b.use(o1)
+		if !ok0 && !ok1 {
+			return nil, statusGcodeNoOffsetsInPlane
+		}
+		i = v0 * unit
+		j = v1 * unit
+
+		r := math.Hypot(i, j)
+		dr := math.Abs(math.Hypot(x-i, y-j) - r)
+		if dr > 0.005 && (dr > 0.5 || dr > 0.001*r) {
+			return nil, statusGcodeInvalidTarget
+		}
+	}
+
+	// offsets not in the selected plane are not used by arcs
+	for _, l := range []byte("IJK") {
+		if l != o0 && l != o1 {
+			if b.has(l) {
+				return nil, statusGcodeUnusedWords
+			}
+		}
+	}
+
+	center0 := gc.position[a0] + i
+	center1 := gc.position[a1] + j
+	r0 := -i
+	r1 := -j
+	rt0 := target[a0] - center0
+	rt1 := target[a1] - center1
+	radius := math.Hypot(i, j)
+
+	angle := math.Atan2(r0*rt1-r1*rt0, r0*rt0+r1*rt1)
+	if gc.motion == 20 {
+		if angle >= -5e-7 {
+			angle -= 2 * math.Pi
+		}
+	} else if angle <= 5e-7 {
+		angle += 2 * math.Pi
+	}
+
+	tol := s.setting(12)
+	segments := int(math.Floor(math.Abs(0.5*angle*radius) / math.Sqrt(tol*(2*radius-tol))))
+
+	rv := [][3]float64{}
+	for k := 1; k < segments; k++ {
+		theta := angle * float64(k) / float64(segments)
+		cos := math.Cos(theta)
+		sin := math.Sin(theta)
+
+		p := [3]float64{}
+		p[a0] = center0 + r0*cos - r1*sin
+		p[a1] = center1 + r0*sin + r1*cos
+		p[linear] = gc.position[linear] + (target[linear]-gc.position[linear])*float64(k)/float64(segments)
+		rv = append(rv, p)
+	}
+
+	return append(rv, target), statusOK
+}
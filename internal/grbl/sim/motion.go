@@ -0,0 +1,277 @@
+package sim
+
+import (
+	"math"
+)
+
+type blockKind int
+
+const (
+	blockLine blockKind = iota
+	blockProbe
+	blockDwell
+	blockHome
+)
+
+type block struct {
+	kind   blockKind
+	target [3]float64
+	rate   float64
+	rapid  bool
+	jog    bool
+
+	// probing
+	away      bool
+	failAlarm bool
+
+	// dwell and homing, in minutes
+	duration float64
+}
+
+// plan adds a block to the planner buffer, waiting for a free slot if
+// needed. must be called with the lock held.
+func (s *Sim) plan(b *block) bool {
+	if b.kind == blockLine && b.target == s.gc.position {
+		return true
+	}
+
+	if !s.wait(func() bool { return len(s.planner) < plannerBufferSize }) {
+		return false
+	}
+
+	s.planner = append(s.planner, b)
+
+	// like grbl, report the cycle as started as soon as a block is queued,
+	// not when the motion loop picks it up. dwells don't start a cycle.
+	if s.state == stateIdle {
+		switch {
+		case b.kind == blockHome:
+			s.state = stateHome
+		case b.jog:
+			s.state = stateJog
+		case b.kind != blockDwell:
+			s.state = stateRun
+		}
+	}
+
+	s.cond.Broadcast()
+	return true
+}
+
+func (s *Sim) touching(p [3]float64) bool {
+	return p[2] <= s.surface(p[0], p[1])
+}
+
+// maxRate returns the maximum feed rate allowed by the per-axis max rate
+// settings, for a move in the given direction.
+func (s *Sim) maxRate(dir [3]float64) float64 {
+	rv := math.Inf(1)
+	for i := 0; i < 3; i++ {
+		if dir[i] == 0 {
+			continue
+		}
+		if r := s.setting(uint8(110+i)) / math.Abs(dir[i]); r < rv {
+			rv = r
+		}
+	}
+	return rv
+}
+
+func (s *Sim) homedPosition() [3]float64 {
+	rv := [3]float64{}
+	for i := 0; i < 3; i++ {
+		if int(s.setting(23))&(1<<i) != 0 {
+			rv[i] = -s.setting(uint8(130+i)) + s.setting(27)
+		} else {
+			rv[i] = -s.setting(27)
+		}
+	}
+	return rv
+}
+
+// step advances the simulation by dt minutes. must be called with the
+// lock held.
+func (s *Sim) step(dt float64) {
+	defer s.cond.Broadcast()
+
+	s.probePn = s.touching(s.mpos)
+
+	switch s.state {
+	case stateHold:
+		s.subState = 0
+		s.rate = 0
+		return
+
+	case stateIdle, stateRun, stateJog, stateHome:
+
+	default:
+		s.rate = 0
+		return
+	}
+
+	if len(s.planner) == 0 {
+		s.rate = 0
+		s.moving = false
+		if s.state != stateIdle {
+			s.state = stateIdle
+		}
+		return
+	}
+
+	b := s.planner[0]
+
+	switch b.kind {
+	case blockDwell:
+		s.rate = 0
+		s.moving = false
+		b.duration -= dt
+		if b.duration <= 0 {
+			s.pop()
+		}
+		return
+
+	case blockHome:
+		s.state = stateHome
+		s.moving = true
+		b.duration -= dt
+		if b.duration <= 0 {
+			s.mpos = s.homedPosition()
+			s.gc.position = s.mpos
+			s.pop()
+		}
+		return
+	}
+
+	if b.jog {
+		s.state = stateJog
+	} else {
+		s.state = stateRun
+	}
+	s.moving = true
+
+	delta := [3]float64{}
+	for i := 0; i < 3; i++ {
+		delta[i] = b.target[i] - s.mpos[i]
+	}
+	dist := distance(b.target, s.mpos)
+	if dist == 0 {
+		s.finishBlock(b)
+		return
+	}
+
+	dir := [3]float64{}
+	for i := 0; i < 3; i++ {
+		dir[i] = delta[i] / dist
+	}
+
+	max := s.maxRate(dir)
+	rate := b.rate
+	if b.rapid {
+		rate = max * float64(s.ovRapid) / 100
+	} else if !b.jog {
+		rate = rate * float64(s.ovFeed) / 100
+	}
+	if rate > max {
+		rate = max
+	}
+	s.rate = rate
+
+	next := b.target
+	if adv := rate * dt; adv < dist {
+		for i := 0; i < 3; i++ {
+			next[i] = s.mpos[i] + dir[i]*adv
+		}
+	}
+
+	if b.kind == blockProbe && s.touching(next) != b.away {
+		// bisect the position where the probe triggered
+		from, to := s.mpos, next
+		for k := 0; k < 30; k++ {
+			mid := [3]float64{}
+			for i := 0; i < 3; i++ {
+				mid[i] = (from[i] + to[i]) / 2
+			}
+			if s.touching(mid) != b.away {
+				to = mid
+			} else {
+				from = mid
+			}
+		}
+
+		s.mpos = to
+		s.probe = to
+		s.probeOK = true
+		s.probePn = !b.away
+		s.planner = s.planner[:1]
+		s.pop()
+		return
+	}
+
+	s.mpos = next
+	if next == b.target {
+		s.finishBlock(b)
+	}
+}
+
+// pop removes the current block from the planner buffer, going idle if
+// the buffer is empty.
+func (s *Sim) pop() {
+	s.planner = s.planner[1:]
+	if len(s.planner) > 0 {
+		return
+	}
+
+	s.rate = 0
+	s.moving = false
+	switch s.state {
+	case stateRun, stateJog, stateHome:
+		s.state = stateIdle
+	}
+}
+
+func (s *Sim) finishBlock(b *block) {
+	s.pop()
+
+	if b.kind == blockProbe {
+		s.probe = s.mpos
+		s.probeOK = false
+		if b.failAlarm {
+			s.alarm(5)
+		}
+	}
+}
+
+// runProbe executes a probing cycle (G38.2-G38.5), waiting for it to
+// finish. must be called with the lock held, and the planner synced.
+func (s *Sim) runProbe(target [3]float64, rate float64, motion int) int {
+	away := motion == 384 || motion == 385
+	failAlarm := motion == 382 || motion == 384
+
+	if s.touching(s.mpos) != away {
+		s.alarm(4)
+		s.send("[PRB:%s:0]", formatPosition(s.probe))
+		return statusOK
+	}
+
+	if !s.plan(&block{
+		kind:      blockProbe,
+		target:    target,
+		rate:      rate,
+		away:      away,
+		failAlarm: failAlarm,
+	}) {
+		return statusAborted
+	}
+	if !s.wait(func() bool { return len(s.planner) == 0 }) {
+		return statusAborted
+	}
+
+	s.gc.position = s.mpos
+
+	ok := 0
+	if s.probeOK {
+		ok = 1
+	}
+	s.send("[PRB:%s:%d]", formatPosition(s.probe), ok)
+	return statusOK
+}
@@ -0,0 +1,97 @@
+package sim
+
+import (
+	"fmt"
+	"sort"
+)
+
+var (
+	// grbl 1.1 defaults, as of defaults.h (DEFAULTS_GENERIC)
+	defaults = map[uint8]float64{
+		0:   10,
+		1:   25,
+		2:   0,
+		3:   0,
+		4:   0,
+		5:   0,
+		6:   0,
+		10:  1,
+		11:  0.010,
+		12:  0.002,
+		13:  0,
+		20:  0,
+		21:  0,
+		22:  0,
+		23:  0,
+		24:  25,
+		25:  500,
+		26:  250,
+		27:  1,
+		30:  1000,
+		31:  0,
+		32:  0,
+		100: 250,
+		101: 250,
+		102: 250,
+		110: 500,
+		111: 500,
+		112: 500,
+		120: 10,
+		121: 10,
+		122: 10,
+		130: 200,
+		131: 200,
+		132: 200,
+	}
+
+	integerSettings = map[uint8]bool{
+		0:  true,
+		1:  true,
+		2:  true,
+		3:  true,
+		4:  true,
+		5:  true,
+		6:  true,
+		10: true,
+		13: true,
+		20: true,
+		21: true,
+		22: true,
+		23: true,
+		26: true,
+		30: true,
+		31: true,
+		32: true,
+	}
+)
+
+func defaultSettings() map[uint8]float64 {
+	rv := map[uint8]float64{}
+	for k, v := range defaults {
+		rv[k] = v
+	}
+	return rv
+}
+
+func (s *Sim) setting(key uint8) float64 {
+	return s.settings[key]
+}
+
+func (s *Sim) reportSettings() {
+	keys := []int{}
+	for k := range s.settings {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	for _, k := range keys {
+		s.send("$%d=%s", k, formatSetting(uint8(k), s.settings[uint8(k)]))
+	}
+}
+
+func formatSetting(key uint8, value float64) string {
+	if integerSettings[key] {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3f", value)
+}
@@ -0,0 +1,593 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rxBufferSize      = 128
+	plannerBufferSize = 15
+	lineBufferSize    = 80
+	version           = "1.1h"
+	versionBuild      = "20190825"
+)
+
+var (
+	ErrIsClosed = errors.New("sim: is closed")
+	ErrOverflow = errors.New("sim: rx buffer overflow")
+)
+
+type state int
+
+const (
+	stateIdle state = iota
+	stateRun
+	stateHold
+	stateJog
+	stateAlarm
+	stateDoor
+	stateCheck
+	stateHome
+	stateSleep
+)
+
+var (
+	stateNames = map[state]string{
+		stateIdle:  "Idle",
+		stateRun:   "Run",
+		stateHold:  "Hold",
+		stateJog:   "Jog",
+		stateAlarm: "Alarm",
+		stateDoor:  "Door",
+		stateCheck: "Check",
+		stateHome:  "Home",
+		stateSleep: "Sleep",
+	}
+)
+
+// Config configures a simulated grbl controller.
+type Config struct {
+	// Surface returns the machine Z coordinate of the workpiece surface at
+	// the given machine X/Y coordinates. Probing cycles stop when touching
+	// it. If nil, a flat surface at Z=-40 is used.
+	Surface func(x float64, y float64) float64
+
+	// Speedup multiplies the speed of the simulated time used by motions,
+	// dwells and homing cycles. Defaults to 1 (real time).
+	Speedup float64
+
+	// Settings overrides the default grbl settings.
+	Settings map[uint8]float64
+}
+
+// Sim is a software emulation of a grbl 1.1 controller. It implements the
+// grbl.Transport interface, speaking the same line protocol as a real board.
+type Sim struct {
+	surface func(x float64, y float64) float64
+	speedup float64
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	gen    int
+
+	rx      []string
+	rxBytes int
+	out     []string
+
+	state    state
+	subState int
+	critical bool
+	moving   bool
+
+	settings map[uint8]float64
+	startup  [2]string
+
+	planner []*block
+	mpos    [3]float64
+	rate    float64
+	probe   [3]float64
+	probeOK bool
+	probePn bool
+
+	gc     *modal
+	coords [6][3]float64
+	g28    [3]float64
+	g30    [3]float64
+	g92    [3]float64
+	tlo    float64
+
+	ovFeed    int
+	ovRapid   int
+	ovSpindle int
+	ovCount   int
+	wcoCount  int
+	lastWCO   [3]float64
+}
+
+// Open starts a simulated grbl controller.
+func Open(cfg *Config) (*Sim, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	rv := &Sim{
+		surface:   cfg.Surface,
+		speedup:   cfg.Speedup,
+		settings:  defaultSettings(),
+		gc:        newModal(),
+		ovFeed:    100,
+		ovRapid:   100,
+		ovSpindle: 100,
+	}
+	rv.cond = sync.NewCond(&rv.mu)
+
+	if rv.surface == nil {
+		rv.surface = func(x float64, y float64) float64 {
+			return -40
+		}
+	}
+	if rv.speedup <= 0 {
+		rv.speedup = 1
+	}
+
+	for k, v := range cfg.Settings {
+		if _, ok := rv.settings[k]; !ok {
+			return nil, fmt.Errorf("sim: invalid setting: $%d", k)
+		}
+		rv.settings[k] = v
+	}
+
+	rv.mu.Lock()
+	if rv.setting(22) != 0 {
+		rv.state = stateAlarm
+	}
+	rv.boot()
+	rv.mu.Unlock()
+
+	go rv.processLoop()
+	go rv.motionLoop()
+
+	return rv, nil
+}
+
+func (s *Sim) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Sim) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrIsClosed
+	}
+
+	s.out = nil
+	return nil
+}
+
+func (s *Sim) ReadLine() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.out) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+
+	if s.closed {
+		return "", ErrIsClosed
+	}
+
+	rv := s.out[0]
+	s.out = s.out[1:]
+	return rv, nil
+}
+
+func (s *Sim) WriteLine(l string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrIsClosed
+	}
+
+	// realtime commands are picked from the stream as soon as they are
+	// received, like grbl does in its serial interrupt.
+	buf := []byte{}
+	for _, b := range []byte(l) {
+		if !s.realtime(b) {
+			buf = append(buf, b)
+		}
+	}
+
+	if s.rxBytes+len(buf)+1 > rxBufferSize {
+		return ErrOverflow
+	}
+
+	s.rx = append(s.rx, string(buf))
+	s.rxBytes += len(buf) + 1
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Sim) WriteRealtime(b byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrIsClosed
+	}
+
+	if !s.realtime(b) {
+		return fmt.Errorf("sim: not a realtime command: 0x%02x", b)
+	}
+	return nil
+}
+
+// TriggerAlarm raises an alarm, as if it was detected by the controller
+// (e.g. a hard limit switch being hit).
+func (s *Sim) TriggerAlarm(code uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alarm(code)
+}
+
+func (s *Sim) send(format string, args ...interface{}) {
+	s.out = append(s.out, fmt.Sprintf(format, args...))
+	s.cond.Broadcast()
+}
+
+func (s *Sim) sendMessage(msg string) {
+	s.send("[MSG:%s]", msg)
+}
+
+func (s *Sim) boot() {
+	s.send("")
+	s.send("Grbl %s ['$' for help]", version)
+
+	if s.state == stateAlarm {
+		s.sendMessage("'$H'|'$X' to unlock")
+		return
+	}
+
+	for _, l := range s.startup {
+		if l == "" {
+			continue
+		}
+		s.send(">%s:%s", l, statusString(s.executeGCode(l, false)))
+	}
+}
+
+func (s *Sim) realtime(b byte) bool {
+	switch b {
+	case '?':
+		s.report()
+
+	case '!':
+		switch s.state {
+		case stateIdle:
+			s.state = stateHold
+			s.subState = 0
+		case stateRun:
+			s.state = stateHold
+			s.subState = 1
+		case stateJog:
+			s.cancelJog()
+		}
+
+	case '~':
+		switch s.state {
+		case stateHold:
+			s.state = stateIdle
+		case stateDoor:
+			if s.subState == 0 {
+				s.state = stateIdle
+			}
+		}
+		s.cond.Broadcast()
+
+	case 0x18:
+		s.reset()
+
+	case 0x84:
+		switch s.state {
+		case stateIdle, stateRun, stateHold, stateJog:
+			if s.state == stateJog {
+				s.cancelJog()
+			}
+			// there's no door switch, so the door is always closed
+			// and ready to resume.
+			s.state = stateDoor
+			s.subState = 0
+		}
+
+	case 0x85:
+		if s.state == stateJog {
+			s.cancelJog()
+		}
+
+	case 0x90:
+		s.setFeedOverride(100)
+	case 0x91:
+		s.setFeedOverride(s.ovFeed + 10)
+	case 0x92:
+		s.setFeedOverride(s.ovFeed - 10)
+	case 0x93:
+		s.setFeedOverride(s.ovFeed + 1)
+	case 0x94:
+		s.setFeedOverride(s.ovFeed - 1)
+
+	case 0x95:
+		s.setRapidOverride(100)
+	case 0x96:
+		s.setRapidOverride(50)
+	case 0x97:
+		s.setRapidOverride(25)
+
+	case 0x99:
+		s.setSpindleOverride(100)
+	case 0x9A:
+		s.setSpindleOverride(s.ovSpindle + 10)
+	case 0x9B:
+		s.setSpindleOverride(s.ovSpindle - 10)
+	case 0x9C:
+		s.setSpindleOverride(s.ovSpindle + 1)
+	case 0x9D:
+		s.setSpindleOverride(s.ovSpindle - 1)
+
+	case 0x9E:
+		if s.state == stateHold && s.gc.spindle != 5 {
+			s.gc.spindle = 5
+			s.ovCount = 0
+		}
+
+	case 0xA0:
+		s.gc.flood = !s.gc.flood
+		s.ovCount = 0
+
+	case 0xA1:
+		s.gc.mist = !s.gc.mist
+		s.ovCount = 0
+
+	default:
+		return b >= 0x80
+	}
+
+	return true
+}
+
+func clamp(v int, min int, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func (s *Sim) setFeedOverride(v int) {
+	s.ovFeed = clamp(v, 10, 200)
+	s.ovCount = 0
+}
+
+func (s *Sim) setRapidOverride(v int) {
+	s.ovRapid = v
+	s.ovCount = 0
+}
+
+func (s *Sim) setSpindleOverride(v int) {
+	s.ovSpindle = clamp(v, 10, 200)
+	s.ovCount = 0
+}
+
+func (s *Sim) cancelJog() {
+	s.flushPlanner()
+	s.state = stateIdle
+	s.gc.position = s.mpos
+}
+
+func (s *Sim) flushPlanner() {
+	s.planner = nil
+	s.moving = false
+	s.rate = 0
+	s.cond.Broadcast()
+}
+
+func (s *Sim) reset() {
+	if s.moving || s.state == stateHome {
+		s.alarm(3)
+	}
+
+	s.gen++
+	s.rx = nil
+	s.rxBytes = 0
+	s.flushPlanner()
+	s.critical = false
+
+	s.gc = newModal()
+	s.gc.position = s.mpos
+	s.setFeedOverride(100)
+	s.setRapidOverride(100)
+	s.setSpindleOverride(100)
+
+	if s.state != stateAlarm {
+		s.state = stateIdle
+	}
+	s.boot()
+}
+
+func (s *Sim) alarm(code uint8) {
+	s.flushPlanner()
+	s.gc.position = s.mpos
+	s.state = stateAlarm
+	s.send("ALARM:%d", code)
+
+	if code == 1 || code == 2 {
+		// hard and soft limits are critical events, that require a reset
+		// before unlocking the controller.
+		s.critical = true
+		s.sendMessage("Reset to continue")
+	}
+}
+
+func (s *Sim) wco() [3]float64 {
+	rv := [3]float64{}
+	for i := 0; i < 3; i++ {
+		rv[i] = s.coords[s.gc.coord][i] + s.g92[i]
+	}
+	rv[2] += s.tlo
+	return rv
+}
+
+func formatPosition(p [3]float64) string {
+	return fmt.Sprintf("%.3f,%.3f,%.3f", p[0], p[1], p[2])
+}
+
+func (s *Sim) report() {
+	st := stateNames[s.state]
+	switch s.state {
+	case stateHold, stateDoor:
+		st = fmt.Sprintf("%s:%d", st, s.subState)
+	}
+
+	fields := []string{st}
+
+	wco := s.wco()
+	if int(s.setting(10))&1 != 0 {
+		fields = append(fields, "MPos:"+formatPosition(s.mpos))
+	} else {
+		wpos := [3]float64{}
+		for i := 0; i < 3; i++ {
+			wpos[i] = s.mpos[i] - wco[i]
+		}
+		fields = append(fields, "WPos:"+formatPosition(wpos))
+	}
+
+	if int(s.setting(10))&2 != 0 {
+		fields = append(fields, fmt.Sprintf("Bf:%d,%d", plannerBufferSize-len(s.planner), rxBufferSize-s.rxBytes))
+	}
+
+	speed := 0.
+	if s.gc.spindle != 5 {
+		speed = s.gc.speed * float64(s.ovSpindle) / 100
+	}
+	fields = append(fields, fmt.Sprintf("FS:%.0f,%.0f", s.rate, speed))
+
+	if s.probePn {
+		fields = append(fields, "Pn:P")
+	}
+
+	if wco != s.lastWCO {
+		s.wcoCount = 0
+		s.lastWCO = wco
+	}
+
+	if s.wcoCount > 0 {
+		s.wcoCount--
+	} else {
+		s.wcoCount = 9
+		if s.ovCount == 0 {
+			s.ovCount = 1
+		}
+		fields = append(fields, "WCO:"+formatPosition(wco))
+	}
+
+	if s.ovCount > 0 {
+		s.ovCount--
+	} else {
+		s.ovCount = 19
+		fields = append(fields, fmt.Sprintf("Ov:%d,%d,%d", s.ovFeed, s.ovRapid, s.ovSpindle))
+
+		acc := ""
+		switch s.gc.spindle {
+		case 3:
+			acc += "S"
+		case 4:
+			acc += "C"
+		}
+		if s.gc.flood {
+			acc += "F"
+		}
+		if s.gc.mist {
+			acc += "M"
+		}
+		if acc != "" {
+			fields = append(fields, "A:"+acc)
+		}
+	}
+
+	s.send("<%s>", strings.Join(fields, "|"))
+}
+
+func (s *Sim) processLoop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		for (len(s.rx) == 0 || s.critical) && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			return
+		}
+
+		line := s.rx[0]
+		s.rx = s.rx[1:]
+		s.rxBytes -= len(line) + 1
+
+		gen := s.gen
+		st := s.execute(line)
+		if st == statusAborted || gen != s.gen {
+			continue
+		}
+		s.send(statusString(st))
+	}
+}
+
+func (s *Sim) motionLoop() {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for now := range ticker.C {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		s.step(now.Sub(last).Minutes() * s.speedup)
+		s.mu.Unlock()
+		last = now
+	}
+}
+
+// wait blocks until cond returns true, returning false if the controller
+// was reset or closed in the meantime. must be called with the lock held.
+func (s *Sim) wait(cond func() bool) bool {
+	gen := s.gen
+	for !cond() {
+		if s.closed || gen != s.gen {
+			return false
+		}
+		s.cond.Wait()
+	}
+	return !s.closed && gen == s.gen
+}
+
+func (s *Sim) sync() bool {
+	return s.wait(func() bool {
+		return len(s.planner) == 0 && s.state != stateHold && s.state != stateDoor
+	})
+}
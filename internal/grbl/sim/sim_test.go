@@ -0,0 +1,281 @@
+package sim_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/sim"
+)
+
+func openGrbl(t *testing.T, cfg *sim.Config) (*grbl.Grbl, *sim.Sim) {
+	t.Helper()
+
+	s, err := sim.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := grbl.NewGrbl(s)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		g.Close()
+	})
+
+	return g, s
+}
+
+func waitIdle(t *testing.T, ctx context.Context, g *grbl.Grbl) {
+	t.Helper()
+
+	for {
+		if err := g.RefreshStatus(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		g.RLock()
+		state := g.State
+		g.RUnlock()
+
+		if state == response.StateIdle {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamJob(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	g, _ := openGrbl(t, &sim.Config{Speedup: 100})
+	g.Streaming = true
+
+	data := "G21 G90\nG0 Z1\n"
+	for i := 1; i <= 20; i++ {
+		data += fmt.Sprintf("G1 X%d Y%d F3000\n(segment %d)\n", i, i%3, i)
+	}
+	j, err := gcode.NewJobFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acked := []int{}
+	if err := g.SendJobWithProgress(ctx, j, func(index int) {
+		acked = append(acked, index)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(acked) != len(j) {
+		t.Fatalf("got %d lines acknowledged, want %d", len(acked), len(j))
+	}
+	for i, index := range acked {
+		if index != i {
+			t.Fatalf("line %d acknowledged out of order: %v", i, acked)
+		}
+	}
+
+	waitIdle(t, ctx, g)
+
+	g.RLock()
+	wpos := g.WPos.Copy()
+	g.RUnlock()
+
+	if wpos.X != 20 || wpos.Y != 2 || wpos.Z != 1 {
+		t.Errorf("unexpected position after job: %s", wpos)
+	}
+}
+
+func TestRunState(t *testing.T) {
+	s, err := sim.Open(&sim.Config{Speedup: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			l, err := s.ReadLine()
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- l
+		}
+	}()
+
+	read := func(prefix string) string {
+		t.Helper()
+
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case l, ok := <-lines:
+				if !ok {
+					t.Fatal("simulator closed")
+				}
+				if strings.HasPrefix(l, prefix) {
+					return l
+				}
+			case <-timeout:
+				t.Fatalf("timeout waiting for %q", prefix)
+			}
+		}
+	}
+
+	read("Grbl ")
+
+	if err := s.WriteLine("G1 X10 F100"); err != nil {
+		t.Fatal(err)
+	}
+	read("ok")
+
+	// the block was just queued, the machine is not moving yet
+	if err := s.WriteRealtime('?'); err != nil {
+		t.Fatal(err)
+	}
+	if l := read("<"); !strings.HasPrefix(l, "<Run|") {
+		t.Errorf("unexpected status with a queued block: %s", l)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	g, _ := openGrbl(t, &sim.Config{
+		Speedup: 100,
+		Surface: func(x float64, y float64) float64 {
+			return -5 + 0.1*x
+		},
+	})
+
+	if err := g.SendGCodeInline(ctx, "G21 G90\nG0 X10 Y10\nG38.2 Z-20 F500\nG4 P0.001"); err != nil {
+		t.Fatal(err)
+	}
+
+	g.RLock()
+	probe := g.LastProbe.Copy()
+	g.RUnlock()
+
+	if math.Abs(probe.Z+4) > 1e-3 {
+		t.Errorf("unexpected probe position: %s", probe)
+	}
+
+	// probing away from the surface fails with an alarm
+	err := g.SendGCodeInline(ctx, "G0 Z1\nG38.2 Z0 F500")
+	aerr := &grbl.AlarmError{}
+	if !errors.As(err, &aerr) || aerr.Alarm.Code != response.AlarmProbeFailContact {
+		t.Errorf("expected probe fail alarm, got: %v", err)
+	}
+}
+
+func TestAlarm(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	g, s := openGrbl(t, &sim.Config{Speedup: 10})
+	g.Streaming = true
+
+	data := "G21 G90\n"
+	for i := 1; i <= 20; i++ {
+		data += fmt.Sprintf("G1 X%d F600\n", 10*i)
+	}
+	j, err := gcode.NewJobFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	once := sync.Once{}
+	err = g.SendJobWithProgress(ctx, j, func(index int) {
+		once.Do(func() {
+			s.TriggerAlarm(response.AlarmHardLimitError)
+		})
+	})
+
+	aerr := &grbl.AlarmError{}
+	if !errors.As(err, &aerr) || aerr.Alarm.Code != response.AlarmHardLimitError {
+		t.Fatalf("expected hard limit alarm, got: %v", err)
+	}
+
+	if err := g.RefreshStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	g.RLock()
+	state := g.State
+	g.RUnlock()
+
+	if state != response.StateAlarm {
+		t.Errorf("unexpected state after alarm: %d", state)
+	}
+
+	// the alarm is cleared after a reset
+	if err := g.SendCommands(ctx, "$X"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SendGCodeInline(ctx, "G0 X0"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineErrorHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, streaming := range []bool{false, true} {
+		g, _ := openGrbl(t, &sim.Config{Speedup: 100})
+		g.Streaming = streaming
+
+		// errors of the lines sent by the tool change are not errors of
+		// the job
+		var toolChangeErr error
+		g.ToolChange = func(ctx context.Context, l gcode.Line) error {
+			toolChangeErr = g.SendGCodeInline(ctx, "M100")
+			return nil
+		}
+
+		j, err := gcode.NewJobFromData("G21 G90\nT1 M6\nG1 X1 F600\nM100\nG1 X2\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lerrs := []*grbl.LineError{}
+		if err := g.SendSourceWithErrorHandler(ctx, j.Source(), nil, func(ctx context.Context, err *grbl.LineError) error {
+			lerrs = append(lerrs, err)
+			return nil
+		}); err != nil {
+			t.Fatalf("streaming=%t: %s", streaming, err)
+		}
+
+		if toolChangeErr == nil {
+			t.Errorf("streaming=%t: expected error from the tool change", streaming)
+		}
+		if len(lerrs) != 1 || lerrs[0].Index != 3 {
+			t.Errorf("streaming=%t: unexpected line errors: %v", streaming, lerrs)
+		}
+
+		waitIdle(t, ctx, g)
+
+		g.RLock()
+		wpos := g.WPos.Copy()
+		g.RUnlock()
+
+		if wpos.X != 2 {
+			t.Errorf("streaming=%t: unexpected position after job: %s", streaming, wpos)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package sim
+
+import (
+	"strconv"
+	"strings"
+)
+
+// execute handles a line received from the stream. must be called with the
+// lock held.
+func (s *Sim) execute(line string) int {
+	if s.state == stateSleep {
+		return statusAborted
+	}
+
+	if len(line) == 0 || line[0] != '$' {
+		return s.executeGCode(line, false)
+	}
+
+	line = preprocess(line)
+	if len(line) >= lineBufferSize {
+		return statusOverflow
+	}
+
+	busy := s.state == stateRun || s.state == stateHold || s.state == stateJog || s.state == stateHome
+
+	switch {
+	case line == "$":
+		s.send("[HLP:$$ $# $G $I $N $x=val $Nx=line $J=line $SLP $C $X $H ~ ! ? ctrl-x]")
+
+	case line == "$$":
+		if busy {
+			return statusIdleError
+		}
+		s.reportSettings()
+
+	case line == "$#":
+		if busy {
+			return statusIdleError
+		}
+		s.reportParameters()
+
+	case line == "$G":
+		s.send("[GC:%s]", s.gc)
+
+	case line == "$I":
+		s.send("[VER:%s.%s:]", version, versionBuild)
+		s.send("[OPT:V,%d,%d]", plannerBufferSize, rxBufferSize)
+
+	case line == "$N":
+		if busy {
+			return statusIdleError
+		}
+		for i, l := range s.startup {
+			s.send("$N%d=%s", i, l)
+		}
+
+	case line == "$X":
+		if busy {
+			return statusIdleError
+		}
+		if s.state == stateAlarm {
+			s.state = stateIdle
+			s.sendMessage("Caution: Unlocked")
+		}
+
+	case line == "$H":
+		if s.setting(22) == 0 {
+			return statusSettingDisabled
+		}
+		if busy {
+			return statusIdleError
+		}
+		s.state = stateIdle
+		s.plan(&block{
+			kind:     blockHome,
+			duration: 2. / 60,
+		})
+		if !s.wait(func() bool { return len(s.planner) == 0 }) {
+			return statusAborted
+		}
+
+	case line == "$C":
+		switch s.state {
+		case stateCheck:
+			s.sendMessage("Disabled")
+			s.send(statusString(statusOK))
+			s.state = stateIdle
+			s.reset()
+			return statusAborted
+
+		case stateIdle:
+			s.state = stateCheck
+			s.sendMessage("Enabled")
+
+		default:
+			return statusIdleError
+		}
+
+	case line == "$SLP":
+		s.flushPlanner()
+		s.state = stateSleep
+		s.sendMessage("Sleeping")
+
+	case strings.HasPrefix(line, "$J="):
+		if s.state != stateIdle && s.state != stateJog {
+			return statusIdleError
+		}
+		return s.executeGCode(line[3:], true)
+
+	case strings.HasPrefix(line, "$RST="):
+		if busy {
+			return statusIdleError
+		}
+		switch line[5:] {
+		case "$":
+			s.settings = defaultSettings()
+		case "#":
+			s.coords = [6][3]float64{}
+			s.g28 = [3]float64{}
+			s.g30 = [3]float64{}
+		case "*":
+			s.settings = defaultSettings()
+			s.coords = [6][3]float64{}
+			s.g28 = [3]float64{}
+			s.g30 = [3]float64{}
+			s.startup = [2]string{}
+		default:
+			return statusInvalidStatement
+		}
+		s.sendMessage("Restoring defaults")
+
+	case strings.HasPrefix(line, "$N") && strings.Contains(line, "="):
+		if busy {
+			return statusIdleError
+		}
+		parts := strings.SplitN(line[2:], "=", 2)
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(s.startup) {
+			return statusInvalidStatement
+		}
+		s.startup[idx] = parts[1]
+
+	case strings.Contains(line, "="):
+		if busy {
+			return statusIdleError
+		}
+		parts := strings.SplitN(line[1:], "=", 2)
+		key, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil {
+			return statusBadNumberFormat
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return statusBadNumberFormat
+		}
+		if value < 0 {
+			return statusNegativeValue
+		}
+		if _, ok := s.settings[uint8(key)]; !ok {
+			return statusInvalidStatement
+		}
+		s.settings[uint8(key)] = value
+
+	default:
+		return statusInvalidStatement
+	}
+
+	return statusOK
+}
+
+func (s *Sim) reportParameters() {
+	for i, c := range s.coords {
+		s.send("[G%d:%s]", 54+i, formatPosition(c))
+	}
+	s.send("[G28:%s]", formatPosition(s.g28))
+	s.send("[G30:%s]", formatPosition(s.g30))
+	s.send("[G92:%s]", formatPosition(s.g92))
+	s.send("[TLO:%.3f]", s.tlo)
+
+	ok := 0
+	if s.probeOK {
+		ok = 1
+	}
+	s.send("[PRB:%s:%d]", formatPosition(s.probe), ok)
+}
@@ -0,0 +1,208 @@
+package grbl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/gcode"
+)
+
+// size of grbl's serial receive buffer
+const rxBufferSize = 128
+
+type streamLine struct {
+	index int
+	line  gcode.Line
+	size  int
+
+	// ignored lines are not sent, but reported after the lines before
+	// them.
+	ignored bool
+}
+
+// needsSync returns true for lines that must be sent using the
+// send-response protocol, because they change settings or rely on
+// feedback from grbl (e.g. probing) before the next line is sent.
+func needsSync(l gcode.Line) bool {
+	for _, f := range l {
+		if f.Letter != 'G' {
+			continue
+		}
+
+		switch f.Value {
+		case 10, 28.1, 30.1, 38.2, 38.3, 38.4, 38.5, 92:
+			return true
+		}
+	}
+	return false
+}
+
+// streamSource sends the lines read from src using the character-counting protocol: lines are
+// written as long as they fit in grbl's receive buffer, and each ok/error
+// response acknowledges the oldest line still pending.
+func (g *Grbl) streamSource(ctx context.Context, src gcode.Source, progress func(index int), onError LineErrorHandler) error {
+	pending := []*streamLine{}
+	used := 0
+	reset := g.resetChan()
+	alarm := g.alarmChan()
+	var firstErr error
+
+	flush := func() {
+		for len(pending) > 0 && pending[0].ignored {
+			if firstErr == nil {
+				progress(pending[0].index)
+			}
+			pending = pending[1:]
+		}
+	}
+
+	ack := func() error {
+		a, err := g.readAck(reset)
+		if err != nil {
+			return err
+		}
+
+		sl := pending[0]
+		pending = pending[1:]
+		used -= sl.size
+		defer flush()
+
+		if a != nil {
+			if firstErr == nil {
+				// errors after the first one that aborts the job are
+				// ignored
+				var gerr Error
+				if !errors.As(a, &gerr) {
+					firstErr = a
+					return nil
+				}
+				firstErr = handleLineError(ctx, alarm, onError, &LineError{
+					Index: sl.index,
+					Line:  sl.line,
+					Err:   gerr,
+				})
+				if firstErr == nil {
+					progress(sl.index)
+				}
+			}
+			return nil
+		}
+
+		g.processGCodeState(sl.line)
+		progress(sl.index)
+		return nil
+	}
+
+	drain := func() error {
+		for len(pending) > 0 {
+			if err := ack(); err != nil {
+				return err
+			}
+		}
+		return firstErr
+	}
+
+	for i := 0; ; i++ {
+		if err := g.waitResume(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return drain()
+		default:
+		}
+
+		l, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// wait for the lines already sent before failing
+			if derr := drain(); derr != nil {
+				return derr
+			}
+			return err
+		}
+
+		if hasToolChange(l) {
+			if err := drain(); err != nil {
+				return err
+			}
+
+			l, err = g.toolChange(ctx, l)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if g.ignored(l) {
+			if len(pending) == 0 {
+				progress(i)
+			} else {
+				pending = append(pending, &streamLine{
+					index:   i,
+					line:    l,
+					ignored: true,
+				})
+			}
+			continue
+		}
+
+		if needsSync(l) {
+			if err := drain(); err != nil {
+				return err
+			}
+			if err := g.sendJobLine(ctx, alarm, onError, i, l); err != nil {
+				return err
+			}
+			progress(i)
+			continue
+		}
+
+		data := l.String()
+		size := len(data) + 1
+		if size > rxBufferSize {
+			if err := drain(); err != nil {
+				return err
+			}
+			return fmt.Errorf("grbl: line %d too long for grbl buffer: %s", i+1, data)
+		}
+
+		for used+size > rxBufferSize {
+			if err := ack(); err != nil {
+				return err
+			}
+		}
+
+		// stop sending new lines after the first error, alarm or reset,
+		// but keep reading the responses for the lines already sent.
+		if firstErr != nil {
+			return drain()
+		}
+		select {
+		case <-alarm:
+			return drain()
+		case <-reset:
+			return ErrReset
+		default:
+		}
+
+		if err := g.t.WriteLine(data); err != nil {
+			return err
+		}
+		pending = append(pending, &streamLine{
+			index: i,
+			line:  l,
+			size:  size,
+		})
+		used += size
+	}
+
+	return drain()
+}
@@ -0,0 +1,22 @@
+package grbl
+
+// Transport is the byte pipe used to talk to a grbl controller. It is
+// implemented by usbserial.UsbSerial, and can be implemented by anything
+// else that speaks grbl's line protocol (tcp bridges, ptys, simulators, ...).
+type Transport interface {
+	// ReadLine blocks until a full line is received, and returns it
+	// without the line terminator.
+	ReadLine() (string, error)
+
+	// WriteLine writes a single line, appending the line terminator.
+	WriteLine(l string) error
+
+	// WriteRealtime writes a single realtime command byte, that is
+	// handled by grbl as soon as it is received, bypassing its line buffer.
+	WriteRealtime(b byte) error
+
+	// Flush discards any pending input and output data.
+	Flush() error
+
+	Close() error
+}
@@ -131,21 +131,11 @@ func (u *UsbSerial) ReadLine() (string, error) {
 	return strings.TrimSpace(string(buf)), nil
 }
 
-func (u *UsbSerial) WriteLine(l string, nl bool) error {
+func (u *UsbSerial) write(p []byte) error {
 	if !u.isOpen {
 		return ErrIsClosed
 	}
 
-	l = strings.TrimSpace(l)
-	if strings.ContainsAny(l, "\r\n") {
-		return fmt.Errorf("usbserial: trying to write multiple lines at once: %s", l)
-	}
-
-	p := []byte(l)
-	if nl {
-		p = append(p, '\n')
-	}
-
 	n := 0
 	for n < len(p) {
 		c, err := unix.Write(u.fd, p[n:])
@@ -159,8 +149,21 @@ func (u *UsbSerial) WriteLine(l string, nl bool) error {
 	}
 
 	if n != len(p) {
-		return fmt.Errorf("usbserial: failed to write full line (%s): %d/%d", l, n, len(p))
+		return fmt.Errorf("usbserial: failed to write full data (%q): %d/%d", p, n, len(p))
 	}
 
 	return nil
 }
+
+func (u *UsbSerial) WriteLine(l string) error {
+	l = strings.TrimSpace(l)
+	if strings.ContainsAny(l, "\r\n") {
+		return fmt.Errorf("usbserial: trying to write multiple lines at once: %s", l)
+	}
+
+	return u.write(append([]byte(l), '\n'))
+}
+
+func (u *UsbSerial) WriteRealtime(b byte) error {
+	return u.write([]byte{b})
+}
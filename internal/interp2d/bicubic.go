@@ -0,0 +1,143 @@
+//go:build !gsl
+// +build !gsl
+
+package interp2d
+
+// initBicubic computes the partial derivatives at each point of the grid,
+// using natural cubic splines along the rows and columns, like gsl does.
+func (s *Spline) initBicubic() {
+	nx := len(s.xa)
+	ny := len(s.ya)
+
+	s.zx = make([]float64, nx*ny)
+	s.zy = make([]float64, nx*ny)
+	s.zxy = make([]float64, nx*ny)
+
+	row := make([]float64, nx)
+	col := make([]float64, ny)
+
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			row[i] = s.za[j*nx+i]
+		}
+		for i, d := range splineDerivs(s.xa, row) {
+			s.zx[j*nx+i] = d
+		}
+	}
+
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			col[j] = s.za[j*nx+i]
+		}
+		for j, d := range splineDerivs(s.ya, col) {
+			s.zy[j*nx+i] = d
+		}
+	}
+
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			row[i] = s.zy[j*nx+i]
+		}
+		for i, d := range splineDerivs(s.xa, row) {
+			s.zxy[j*nx+i] = d
+		}
+	}
+}
+
+// evalBicubic evaluates the bicubic patch that starts at the grid point
+// (xi, yi), at the normalized coordinates (t, u).
+func (s *Spline) evalBicubic(xi int, yi int, t float64, u float64) float64 {
+	nx := len(s.xa)
+
+	dx := s.xa[xi+1] - s.xa[xi]
+	dy := s.ya[yi+1] - s.ya[yi]
+
+	idx := [2][2]int{
+		{yi*nx + xi, (yi+1)*nx + xi},
+		{yi*nx + xi + 1, (yi+1)*nx + xi + 1},
+	}
+
+	// values and derivatives at the corners, scaled to the unit square
+	f := [4][4]float64{}
+	for a := 0; a < 2; a++ {
+		for b := 0; b < 2; b++ {
+			f[a][b] = s.za[idx[a][b]]
+			f[a][b+2] = s.zy[idx[a][b]] * dy
+			f[a+2][b] = s.zx[idx[a][b]] * dx
+			f[a+2][b+2] = s.zxy[idx[a][b]] * dx * dy
+		}
+	}
+
+	// hermite basis: coefficients = m * f * transpose(m)
+	m := [4][4]float64{
+		{1, 0, 0, 0},
+		{0, 0, 1, 0},
+		{-3, 3, -2, -1},
+		{2, -2, 1, 1},
+	}
+
+	mf := [4][4]float64{}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				mf[i][j] += m[i][k] * f[k][j]
+			}
+		}
+	}
+
+	tp := [4]float64{1, t, t * t, t * t * t}
+	up := [4]float64{1, u, u * u, u * u * u}
+
+	rv := 0.
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			c := 0.
+			for k := 0; k < 4; k++ {
+				c += mf[i][k] * m[j][k]
+			}
+			rv += c * tp[i] * up[j]
+		}
+	}
+	return rv
+}
+
+// splineDerivs returns the first derivatives at the knots of the natural
+// cubic spline that interpolates the given points.
+func splineDerivs(xa []float64, ya []float64) []float64 {
+	n := len(xa)
+	rv := make([]float64, n)
+
+	h := make([]float64, n-1)
+	slope := make([]float64, n-1)
+	for i := 0; i < n-1; i++ {
+		h[i] = xa[i+1] - xa[i]
+		slope[i] = (ya[i+1] - ya[i]) / h[i]
+	}
+
+	// second derivatives, zero at the ends for a natural spline. the
+	// tridiagonal system is solved with the thomas algorithm.
+	m := make([]float64, n)
+	if n > 2 {
+		diag := make([]float64, n)
+		rhs := make([]float64, n)
+		for i := 1; i < n-1; i++ {
+			diag[i] = 2 * (h[i-1] + h[i])
+			rhs[i] = 6 * (slope[i] - slope[i-1])
+		}
+		for i := 2; i < n-1; i++ {
+			w := h[i-1] / diag[i-1]
+			diag[i] -= w * h[i-1]
+			rhs[i] -= w * rhs[i-1]
+		}
+		for i := n - 2; i > 0; i-- {
+			m[i] = (rhs[i] - h[i]*m[i+1]) / diag[i]
+		}
+	}
+
+	for i := 0; i < n-1; i++ {
+		rv[i] = slope[i] - h[i]*(2*m[i]+m[i+1])/6
+	}
+	rv[n-1] = slope[n-2] + h[n-2]*(m[n-2]+2*m[n-1])/6
+
+	return rv
+}
@@ -1,3 +1,6 @@
+//go:build gsl
+// +build gsl
+
 package interp2d
 
 import (
@@ -1,26 +1,40 @@
+//go:build !gsl
+// +build !gsl
+
 package interp2d
 
 import (
 	"errors"
-	"math"
+	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
 )
 
-// FIXME: port to pure go, maybe? ¯\_(ツ)_/¯
+type interpType int
 
-// #cgo pkg-config: gsl
-// #include <gsl/gsl_errno.h>
-// #include <gsl/gsl_math.h>
-// #include <gsl/gsl_interp2d.h>
-// #include <gsl/gsl_spline2d.h>
-import "C"
+const (
+	bilinear interpType = iota
+	bicubic
+)
 
+// Spline interpolates a regular grid of points, using the same algorithms
+// as gsl_spline2d: bicubic for grids of at least 4x4 points, and bilinear
+// otherwise. it is safe for concurrent use.
 type Spline struct {
-	ptr *C.gsl_spline2d
+	mu sync.RWMutex
+
+	typ interpType
 
-	xacc *C.gsl_interp_accel
-	yacc *C.gsl_interp_accel
+	xa []float64
+	ya []float64
+	za []float64
+
+	// partial derivatives, for bicubic interpolation
+	zx  []float64
+	zy  []float64
+	zxy []float64
 
 	closed bool
 }
@@ -31,19 +45,15 @@ func NewSpline(points [][]*point.Point) (*Spline, error) {
 		return nil, errors.New("interp2d: no points")
 	}
 
-	nx := -1
+	nx := len(points[0])
+	if nx == 0 {
+		return nil, errors.New("interp2d: no points")
+	}
+
 	ya := []float64{}
 	for _, lp := range points {
-		if nx == -1 {
-			nx = len(lp)
-		} else {
-			if nx != len(lp) {
-				return nil, errors.New("interp2d: invalid matrix of points")
-			}
-
-			if nx < 1 {
-				return nil, errors.New("interp2d: no points")
-			}
+		if nx != len(lp) {
+			return nil, errors.New("interp2d: invalid matrix of points")
 		}
 
 		ya = append(ya, lp[0].Y)
@@ -54,75 +64,109 @@ func NewSpline(points [][]*point.Point) (*Spline, error) {
 		xa = append(xa, p.X)
 	}
 
-	// min_size could be grabbed from the type
-	typ := C.gsl_interp2d_bicubic
-	if nx < 4 || ny < 4 {
-		typ = C.gsl_interp2d_bilinear
-	} else if nx < 2 || ny < 2 {
+	if nx < 2 || ny < 2 {
 		return nil, errors.New("interp2d: matrix of points must be at least 2x2")
 	}
 
-	spline := C.gsl_spline2d_alloc(typ, C.size_t(nx), C.size_t(ny))
-	if spline == nil {
-		return nil, lastError
+	if !increasing(xa) {
+		return nil, errors.New("interp2d: x values must be strictly increasing")
+	}
+
+	if !increasing(ya) {
+		return nil, errors.New("interp2d: y values must be strictly increasing")
 	}
 
 	za := make([]float64, nx*ny)
 	for j, lp := range points {
 		for i, p := range lp {
-			C.gsl_spline2d_set(spline, (*C.double)(&za[0]), C.size_t(i), C.size_t(j), C.double(p.Z))
+			za[j*nx+i] = p.Z
 		}
 	}
 
-	if C.GSL_SUCCESS != C.gsl_spline2d_init(spline, (*C.double)(&xa[0]), (*C.double)(&ya[0]), (*C.double)(&za[0]), C.size_t(nx), C.size_t(ny)) {
-		return nil, lastError
-	}
-
-	xacc := C.gsl_interp_accel_alloc()
-	if xacc == nil {
-		return nil, lastError
+	rv := &Spline{
+		typ: bicubic,
+		xa:  xa,
+		ya:  ya,
+		za:  za,
 	}
 
-	yacc := C.gsl_interp_accel_alloc()
-	if yacc == nil {
-		return nil, lastError
+	if nx < 4 || ny < 4 {
+		rv.typ = bilinear
+		return rv, nil
 	}
 
-	return &Spline{
-		ptr:  spline,
-		xacc: xacc,
-		yacc: yacc,
-	}, nil
+	rv.initBicubic()
+	return rv, nil
 }
 
 func (s *Spline) Close() {
-	if s == nil || s.closed {
+	if s == nil {
 		return
 	}
 
-	if s.ptr != nil {
-		C.gsl_spline2d_free(s.ptr)
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+func (s *Spline) At(x float64, y float64) (float64, error) {
+	if s == nil {
+		return 0, errors.New("interp2d: invalid spline")
 	}
 
-	if s.xacc != nil {
-		C.gsl_interp_accel_free(s.xacc)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, errors.New("interp2d: invalid spline")
 	}
 
-	if s.yacc != nil {
-		C.gsl_interp_accel_free(s.yacc)
+	nx := len(s.xa)
+	ny := len(s.ya)
+
+	if x < s.xa[0] || x > s.xa[nx-1] || y < s.ya[0] || y > s.ya[ny-1] {
+		return 0, fmt.Errorf("interp2d: interpolation error: point out of range: (%.3f, %.3f)", x, y)
 	}
 
-	s.closed = true
+	xi := search(s.xa, x)
+	yi := search(s.ya, y)
+
+	t := (x - s.xa[xi]) / (s.xa[xi+1] - s.xa[xi])
+	u := (y - s.ya[yi]) / (s.ya[yi+1] - s.ya[yi])
+
+	if s.typ == bicubic {
+		return s.evalBicubic(xi, yi, t, u), nil
+	}
+
+	z00 := s.za[yi*nx+xi]
+	z10 := s.za[yi*nx+xi+1]
+	z01 := s.za[(yi+1)*nx+xi]
+	z11 := s.za[(yi+1)*nx+xi+1]
+
+	return (1-t)*(1-u)*z00 + t*(1-u)*z10 + (1-t)*u*z01 + t*u*z11, nil
 }
 
-func (s *Spline) At(x float64, y float64) (float64, error) {
-	if s == nil || s.closed {
-		return 0, errors.New("interp2d: invalid spline")
+func increasing(a []float64) bool {
+	for i := 1; i < len(a); i++ {
+		if a[i] <= a[i-1] {
+			return false
+		}
 	}
+	return true
+}
+
+// search returns the index i of the interval a[i] <= v < a[i+1] that
+// contains v, or the last interval if v is the last value.
+func search(a []float64, v float64) int {
+	i := sort.Search(len(a), func(k int) bool {
+		return a[k] > v
+	}) - 1
 
-	rv := float64(C.gsl_spline2d_eval(s.ptr, C.double(x), C.double(y), s.xacc, s.yacc))
-	if math.IsNaN(rv) {
-		return 0, lastError
+	if i < 0 {
+		return 0
 	}
-	return rv, nil
+	if i > len(a)-2 {
+		return len(a) - 2
+	}
+	return i
 }
@@ -0,0 +1,253 @@
+package interp2d
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+func grid(xa []float64, ya []float64, za [][]float64) [][]*point.Point {
+	rv := [][]*point.Point{}
+	for j, y := range ya {
+		row := []*point.Point{}
+		for i, x := range xa {
+			row = append(row, &point.Point{X: x, Y: y, Z: za[j][i]})
+		}
+		rv = append(rv, row)
+	}
+	return rv
+}
+
+func checkAt(t *testing.T, s *Spline, x float64, y float64, z float64) {
+	t.Helper()
+
+	v, err := s.At(x, y)
+	if err != nil {
+		t.Fatalf("(%g, %g): unexpected error: %s", x, y, err)
+	}
+	if math.Abs(v-z) > 1e-9 {
+		t.Errorf("(%g, %g): got %.15g, want %.15g", x, y, v, z)
+	}
+}
+
+func TestBilinear(t *testing.T) {
+	// example from the gsl documentation for gsl_interp2d_bilinear
+	s, err := NewSpline(grid(
+		[]float64{0, 1},
+		[]float64{0, 1},
+		[][]float64{
+			{0, 1},
+			{1, 0.5},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, tt := range []struct {
+		x, y, z float64
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{0, 1, 1},
+		{1, 1, 0.5},
+		{0.5, 0.5, 0.625},
+		{0.25, 0.75, 0.71875},
+		{1, 0.5, 0.75},
+		{0.5, 1, 0.75},
+	} {
+		checkAt(t, s, tt.x, tt.y, tt.z)
+	}
+}
+
+func TestBilinearSmallGrid(t *testing.T) {
+	// grids with less than 4 points in any direction fall back to bilinear
+	s, err := NewSpline(grid(
+		[]float64{0, 2, 3, 5, 6},
+		[]float64{0, 1, 4},
+		[][]float64{
+			{0, 1, 2, 1, 0},
+			{1, 2, 3, 2, 1},
+			{0, 0, 4, 0, 0},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, tt := range []struct {
+		x, y, z float64
+	}{
+		{1, 0.5, 1},
+		{2.5, 2.5, 2.25},
+		{5.5, 4, 0},
+		{6, 1, 1},
+		{4, 0, 1.5},
+	} {
+		checkAt(t, s, tt.x, tt.y, tt.z)
+	}
+}
+
+func TestBicubic(t *testing.T) {
+	// reference values computed with gsl_interp2d_bicubic (natural cubic
+	// spline derivatives along rows and columns)
+	s, err := NewSpline(grid(
+		[]float64{0, 1, 2.5, 4, 6},
+		[]float64{0, 2, 3, 5},
+		[][]float64{
+			{0, 0.1, 0.3, 0.2, -0.1},
+			{0.1, 0.3, 0.5, 0.4, 0},
+			{0.2, 0.2, 0.6, 0.7, 0.1},
+			{-0.1, 0, 0.2, 0.3, 0.2},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, tt := range []struct {
+		x, y, z float64
+	}{
+		{0.5, 0.5, 0.0975049628586066},
+		{1.5, 2.5, 0.359530518149883},
+		{3, 4, 0.584468459970509},
+		{5, 1, 0.0803959309133489},
+		{5.5, 4.5, 0.287898343731704},
+
+		// grid points and edges
+		{0, 0, 0},
+		{4, 2, 0.4},
+		{0, 5, -0.1},
+		{6, 5, 0.2},
+	} {
+		checkAt(t, s, tt.x, tt.y, tt.z)
+	}
+}
+
+func TestBicubicLinear(t *testing.T) {
+	// bicubic interpolation is exact for bilinear functions
+	f := func(x float64, y float64) float64 {
+		return 0.5 + 0.25*x - 0.125*y + 0.05*x*y
+	}
+
+	xa := []float64{-10, -4, 0, 3, 10}
+	ya := []float64{-5, 0, 1, 7}
+	za := [][]float64{}
+	for _, y := range ya {
+		row := []float64{}
+		for _, x := range xa {
+			row = append(row, f(x, y))
+		}
+		za = append(za, row)
+	}
+
+	s, err := NewSpline(grid(xa, ya, za))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for x := -10.; x <= 10; x += 0.5 {
+		for y := -5.; y <= 7; y += 0.5 {
+			checkAt(t, s, x, y, f(x, y))
+		}
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	s, err := NewSpline(grid(
+		[]float64{0, 1, 2, 3},
+		[]float64{0, 1, 2, 3},
+		[][]float64{
+			{0, 0, 0, 0},
+			{0, 1, 1, 0},
+			{0, 1, 1, 0},
+			{0, 0, 0, 0},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, tt := range []struct {
+		x, y float64
+	}{
+		{-0.001, 0},
+		{0, -0.001},
+		{3.001, 3},
+		{3, 3.001},
+		{10, 10},
+	} {
+		if _, err := s.At(tt.x, tt.y); err == nil {
+			t.Errorf("(%g, %g): expected error", tt.x, tt.y)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		points [][]*point.Point
+	}{
+		{"empty", nil},
+		{"empty row", [][]*point.Point{{}}},
+		{"1x1", grid([]float64{0}, []float64{0}, [][]float64{{0}})},
+		{"1x2", grid([]float64{0}, []float64{0, 1}, [][]float64{{0}, {0}})},
+		{"2x1", grid([]float64{0, 1}, []float64{0}, [][]float64{{0, 0}})},
+		{"ragged", [][]*point.Point{
+			{{X: 0, Y: 0}, {X: 1, Y: 0}},
+			{{X: 0, Y: 1}},
+		}},
+		{"x not increasing", grid([]float64{1, 0}, []float64{0, 1}, [][]float64{{0, 0}, {0, 0}})},
+		{"y not increasing", grid([]float64{0, 1}, []float64{1, 1}, [][]float64{{0, 0}, {0, 0}})},
+	} {
+		if _, err := NewSpline(tt.points); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	s, err := NewSpline(grid(
+		[]float64{0, 1},
+		[]float64{0, 1},
+		[][]float64{
+			{0, 1},
+			{0.5, 1},
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 1000; k++ {
+				s.At(0.5, 0.5)
+			}
+		}()
+	}
+	s.Close()
+	wg.Wait()
+
+	if _, err := s.At(0.5, 0.5); err == nil {
+		t.Error("expected error after close")
+	}
+
+	s.Close()
+
+	var nilSpline *Spline
+	nilSpline.Close()
+	if _, err := nilSpline.At(0, 0); err == nil {
+		t.Error("expected error for nil spline")
+	}
+}
@@ -15,9 +15,9 @@ type Point struct {
 
 func NewFromInches(x float64, y float64, z float64) *Point {
 	return &Point{
-		X: x / 25.4,
-		Y: y / 25.4,
-		Z: z / 25.4,
+		X: x * 25.4,
+		Y: y * 25.4,
+		Z: z * 25.4,
 	}
 }
 
@@ -45,7 +45,7 @@ func NewFromStringMM(str string) (*Point, error) {
 }
 
 func (p *Point) ToInches() (float64, float64, float64) {
-	return p.X * 25.4, p.Y * 25.4, p.Z * 25.4
+	return p.X / 25.4, p.Y / 25.4, p.Z / 25.4
 }
 
 func (p *Point) String() string {
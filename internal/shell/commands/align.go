@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type alignCommand struct{}
+
+func (*alignCommand) GetName() string {
+	return "align"
+}
+
+func (*alignCommand) GetCompletions(args []string) []string {
+	return []string{"pin1=", "pin2=", "apply"}
+}
+
+func (*alignCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("align: argument required: pin1=X,Y, pin2=X,Y or apply")
+	}
+
+	for _, arg := range args {
+		if arg == "apply" {
+			angle, err := a.Align(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("rotation: %.3f degrees\n", angle)
+			continue
+		}
+
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || (parts[0] != "pin1" && parts[0] != "pin2") {
+			return fmt.Errorf("align: invalid argument: %s", arg)
+		}
+
+		v, err := parseFloats(parts[1], 2)
+		if err != nil {
+			return fmt.Errorf("align: invalid pin position: %w", err)
+		}
+
+		pin := 1
+		if parts[0] == "pin2" {
+			pin = 2
+		}
+		if err := a.AlignPin(ctx, pin, v[0], v[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
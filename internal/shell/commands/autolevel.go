@@ -2,8 +2,14 @@ package commands
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/eiannone/keyboard"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
 )
 
 type autolevelCommand struct{}
@@ -13,9 +19,56 @@ func (*autolevelCommand) GetName() string {
 }
 
 func (*autolevelCommand) GetCompletions(args []string) []string {
-	return nil
+	return []string{"spacing=", "nx=", "ny=", "margin="}
 }
 
 func (*autolevelCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
-	return a.AutoLevel(ctx)
+	cfg := autolevel.GridConfig{
+		Spacing: autolevel.DefaultGridSpacing,
+		Margin:  autolevel.DefaultGridMargin,
+	}
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("autolevel: invalid argument: %s", arg)
+		}
+
+		var err error
+		switch parts[0] {
+		case "spacing":
+			cfg.Spacing, err = strconv.ParseFloat(parts[1], 64)
+		case "nx":
+			cfg.NX, err = strconv.Atoi(parts[1])
+		case "ny":
+			cfg.NY, err = strconv.Atoi(parts[1])
+		case "margin":
+			cfg.Margin, err = strconv.ParseFloat(parts[1], 64)
+		default:
+			return fmt.Errorf("autolevel: invalid argument: %s", arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	grid, err := a.AutoLevelGrid(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(grid.Preview())
+	fmt.Print("Start probing? [y/N] ")
+
+	ch, _, err := keyboard.GetSingleKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if ch != 'y' && ch != 'Y' {
+		return errors.New("autolevel: cancelled")
+	}
+
+	return a.AutoLevel(ctx, grid)
 }
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type autolevelConfigCommand struct{}
+
+func (*autolevelConfigCommand) GetName() string {
+	return "autolevel-config"
+}
+
+func (*autolevelConfigCommand) GetCompletions(args []string) []string {
+	return []string{"arc-tolerance=", "max-segment-length=", "safe-height="}
+}
+
+func (*autolevelConfigCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	cfg := a.AutoLevelCfg
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("autolevel-config: invalid argument: %s", arg)
+		}
+
+		var err error
+		switch parts[0] {
+		case "arc-tolerance":
+			cfg.ArcTolerance, err = strconv.ParseFloat(parts[1], 64)
+			if err == nil && cfg.ArcTolerance <= 0 {
+				err = fmt.Errorf("autolevel-config: invalid arc tolerance: %s", parts[1])
+			}
+		case "max-segment-length":
+			cfg.MaxSegmentLength, err = strconv.ParseFloat(parts[1], 64)
+			if err == nil && cfg.MaxSegmentLength <= 0 {
+				err = fmt.Errorf("autolevel-config: invalid max segment length: %s", parts[1])
+			}
+		case "safe-height":
+			cfg.SafeHeight, err = strconv.ParseFloat(parts[1], 64)
+		default:
+			return fmt.Errorf("autolevel-config: invalid argument: %s", arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	a.AutoLevelCfg = cfg
+
+	fmt.Printf("arc-tolerance: %.4f mm\n", cfg.ArcTolerance)
+	fmt.Printf("max-segment-length: %.3f mm\n", cfg.MaxSegmentLength)
+	fmt.Printf("safe-height: %.3f mm\n", cfg.SafeHeight)
+	return nil
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type checkCommand struct{}
+
+func (*checkCommand) GetName() string {
+	return "check"
+}
+
+func (*checkCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*checkCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	r, err := a.Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range r.Errors {
+		fmt.Println(e)
+	}
+
+	if len(r.Errors) == 0 {
+		fmt.Printf("check: %d lines checked, no errors found\n", r.Lines)
+	} else {
+		fmt.Printf("check: %d lines checked, %d errors found\n", r.Lines, len(r.Errors))
+	}
+	return nil
+}
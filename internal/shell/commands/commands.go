@@ -17,15 +17,42 @@ type Command interface {
 
 var (
 	commands = []Command{
+		&alignCommand{},
 		&autolevelCommand{},
+		&autolevelConfigCommand{},
 		&autolevelLoadCommand{},
+		&checkCommand{},
+		&cycleStartCommand{},
+		&errorPolicyCommand{},
+		&feedHoldCommand{},
+		&feedOverrideCommand{},
 		&gotoOriginCommand{},
 		&homeCommand{},
+		&infoCommand{},
 		&jogCommand{},
+		&jogCancelCommand{},
 		&loadCommand{},
+		&mirrorCommand{},
+		&rapidOverrideCommand{},
+		&recoverCommand{},
 		&resetCommand{},
+		&resumeCommand{},
+		&safetyDoorCommand{},
+		&settingsCommand{},
+		&settingsDiffCommand{},
+		&settingsRestoreCommand{},
+		&settingsSaveCommand{},
+		&settingsSetCommand{},
+		&spindleOverrideCommand{},
 		&startCommand{},
+		&statusIntervalCommand{},
+		&streamingCommand{},
+		&toolChangeConfigCommand{},
 		&unlockCommand{},
+		&wcsCommand{},
+		&wcsLoadCommand{},
+		&wcsSaveCommand{},
+		&wcsZeroCommand{},
 		&xyZeroCommand{},
 		&zProbeCommand{},
 	}
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type cycleStartCommand struct{}
+
+func (*cycleStartCommand) GetName() string {
+	return "cycle-start"
+}
+
+func (*cycleStartCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*cycleStartCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	return a.CycleStart(ctx)
+}
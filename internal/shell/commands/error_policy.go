@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type errorPolicyCommand struct{}
+
+func (*errorPolicyCommand) GetName() string {
+	return "error-policy"
+}
+
+func (*errorPolicyCommand) GetCompletions(args []string) []string {
+	return []string{"abort", "pause", "skip"}
+}
+
+func (*errorPolicyCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) > 1 {
+		return errors.New("error-policy: too many arguments")
+	}
+
+	if len(args) == 1 {
+		p, err := actions.ParseErrorPolicy(args[0])
+		if err != nil {
+			return err
+		}
+		a.ErrorPolicy = p
+	}
+
+	fmt.Printf("error policy: %s\n", a.ErrorPolicy)
+	return nil
+}
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type feedHoldCommand struct{}
+
+func (*feedHoldCommand) GetName() string {
+	return "feed-hold"
+}
+
+func (*feedHoldCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*feedHoldCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	return a.FeedHold(ctx)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type feedOverrideCommand struct{}
+
+func (*feedOverrideCommand) GetName() string {
+	return "feed-override"
+}
+
+func (*feedOverrideCommand) GetCompletions(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return []string{"+10", "-10", "+1", "-1", "reset"}
+}
+
+func (*feedOverrideCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("feed-override: delta not defined")
+	}
+
+	if args[0] == "reset" {
+		return a.FeedOverrideReset(ctx)
+	}
+
+	delta, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	return a.FeedOverride(ctx, delta)
+}
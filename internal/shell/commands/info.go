@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type infoCommand struct{}
+
+func (*infoCommand) GetName() string {
+	return "info"
+}
+
+func (*infoCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func (*infoCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	e, err := a.Estimate(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("file: %s\n", a.CurrentJobFile)
+	fmt.Printf("lines: %d\n", e.Lines)
+	if minx, miny, maxx, maxy, err := a.BoundingBox(); err == nil {
+		fmt.Printf("area: X=%.3f,Y=%.3f -> X=%.3f,Y=%.3f\n", minx, miny, maxx, maxy)
+	}
+	fmt.Printf("estimated time: %s\n", formatDuration(e.Time))
+	fmt.Printf("  cutting: %s (%.1f mm)\n", formatDuration(e.CuttingTime), e.CuttingDistance)
+	fmt.Printf("  travel: %s (%.1f mm)\n", formatDuration(e.TravelTime), e.TravelDistance)
+	if e.DwellTime > 0 {
+		fmt.Printf("  dwell: %s\n", formatDuration(e.DwellTime))
+	}
+
+	if len(e.Tools) > 1 {
+		for _, t := range e.Tools {
+			fmt.Printf("tool T%.0f: %s, cutting: %s (%.1f mm), travel: %s (%.1f mm)\n",
+				t.Tool, formatDuration(t.Time),
+				formatDuration(t.CuttingTime), t.CuttingDistance,
+				formatDuration(t.TravelTime), t.TravelDistance)
+		}
+	}
+
+	return nil
+}
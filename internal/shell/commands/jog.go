@@ -26,7 +26,7 @@ func (*jogCommand) Run(ctx context.Context, a *actions.Actions, args []string) e
 
 	step := 1.
 
-	fmt.Println("Press 'q' to quit jogging. F1/F2 to change step.")
+	fmt.Println("Press 'q' to quit jogging. F1/F2 to change step. Space to cancel jog.")
 	fmt.Printf("step: %.3f\n", step)
 
 	for {
@@ -53,6 +53,8 @@ func (*jogCommand) Run(ctx context.Context, a *actions.Actions, args []string) e
 				return a.Jog(ctx, 0, 0, -step)
 			case keyboard.KeyPgup:
 				return a.Jog(ctx, 0, 0, step)
+			case keyboard.KeySpace:
+				return a.JogCancel(ctx)
 			case keyboard.KeyF1:
 				if step <= 10. {
 					step /= 10
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type jogCancelCommand struct{}
+
+func (*jogCancelCommand) GetName() string {
+	return "jog-cancel"
+}
+
+func (*jogCancelCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*jogCancelCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	return a.JogCancel(ctx)
+}
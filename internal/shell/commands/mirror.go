@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type mirrorCommand struct{}
+
+func (*mirrorCommand) GetName() string {
+	return "mirror"
+}
+
+func (*mirrorCommand) GetCompletions(args []string) []string {
+	return []string{"x=", "y="}
+}
+
+func (*mirrorCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) != 1 {
+		return errors.New("mirror: axis not defined, use x=VALUE or y=VALUE")
+	}
+
+	parts := strings.SplitN(args[0], "=", 2)
+	if len(parts) != 2 || (parts[0] != "x" && parts[0] != "y") {
+		return fmt.Errorf("mirror: invalid argument: %s", args[0])
+	}
+
+	v, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return err
+	}
+
+	return a.Mirror(rune(strings.ToUpper(parts[0])[0]), v)
+}
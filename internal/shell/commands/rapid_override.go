@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type rapidOverrideCommand struct{}
+
+func (*rapidOverrideCommand) GetName() string {
+	return "rapid-override"
+}
+
+func (*rapidOverrideCommand) GetCompletions(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return []string{"100", "50", "25"}
+}
+
+func (*rapidOverrideCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("rapid-override: percentage not defined")
+	}
+
+	percent, err := strconv.Atoi(strings.TrimSuffix(args[0], "%"))
+	if err != nil {
+		return err
+	}
+	return a.RapidOverride(ctx, percent)
+}
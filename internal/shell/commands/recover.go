@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eiannone/keyboard"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type recoverCommand struct{}
+
+func (*recoverCommand) GetName() string {
+	return "recover"
+}
+
+func (*recoverCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func confirm(prompt string) (bool, error) {
+	fmt.Print(prompt + " [y/N] ")
+
+	ch, _, err := keyboard.GetSingleKey()
+	if err != nil {
+		return false, err
+	}
+	fmt.Println()
+
+	return ch == 'y' || ch == 'Y', nil
+}
+
+func (*recoverCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	r, err := a.Alarm(ctx)
+	if err != nil {
+		return err
+	}
+
+	if r == nil {
+		fmt.Println("grbl is not in alarm state")
+		return nil
+	}
+
+	if r.Alarm != nil {
+		fmt.Printf("ALARM:%d: %s\n", r.Alarm.Code, r.Alarm.Message)
+	}
+	fmt.Println(r.Description)
+
+	prompt := "Re-home the machine?"
+	switch r.Action {
+	case actions.RecoveryUnlock:
+		prompt = "Unlock the machine?"
+	case actions.RecoveryProbe:
+		prompt = "Unlock the machine and retract the probe?"
+	}
+
+	ok, err := confirm(prompt)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := a.Recover(ctx, r); err != nil {
+		return err
+	}
+
+	if r.Action == actions.RecoveryProbe {
+		ok, err := confirm("Retry probing Z?")
+		if err != nil || !ok {
+			return err
+		}
+		return a.ProbeZ(ctx)
+	}
+
+	if last := a.LastLine(); last >= 0 && last < a.RunningJobLines()-1 {
+		fmt.Printf("the job stopped after line %d, use 'resume' to continue it\n", last+1)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type resumeCommand struct{}
+
+func (*resumeCommand) GetName() string {
+	return "resume"
+}
+
+func (*resumeCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*resumeCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	line := 0
+	if len(args) > 0 {
+		var err error
+		line, err = strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	return runJob(ctx, a, func(ctx context.Context) error {
+		return a.Resume(ctx, line)
+	})
+}
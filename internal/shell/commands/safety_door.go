@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type safetyDoorCommand struct{}
+
+func (*safetyDoorCommand) GetName() string {
+	return "safety-door"
+}
+
+func (*safetyDoorCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*safetyDoorCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	return a.SafetyDoor(ctx)
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/settings"
+)
+
+type settingsCommand struct{}
+
+func (*settingsCommand) GetName() string {
+	return "settings"
+}
+
+func (*settingsCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func parseSettingKey(v string) (uint8, error) {
+	if len(v) > 0 && v[0] == '$' {
+		v = v[1:]
+	}
+	k, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid setting: %s", v)
+	}
+	return uint8(k), nil
+}
+
+func (*settingsCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	s, err := a.Settings(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := settings.Keys(s)
+	if len(args) > 0 {
+		keys = nil
+		for _, arg := range args {
+			k, err := parseSettingKey(arg)
+			if err != nil {
+				return fmt.Errorf("settings: %w", err)
+			}
+			if _, ok := s[k]; !ok {
+				return fmt.Errorf("settings: setting not found: $%d", k)
+			}
+			keys = append(keys, k)
+		}
+	}
+
+	for _, k := range keys {
+		v := settings.Format(k, s[k])
+		info := settings.Lookup(k)
+		if info == nil {
+			fmt.Printf("$%-4d %-10s (unknown)\n", k, v)
+			continue
+		}
+
+		if d := settings.Decode(k, s[k]); d != "" {
+			v += " (" + d + ")"
+		}
+		fmt.Printf("$%-4d %-32s %-20s %s\n", k, info.Name, v, info.Unit)
+		if len(args) > 0 {
+			fmt.Printf("      %s\n", info.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/settings"
+)
+
+type settingsDiffCommand struct{}
+
+func (*settingsDiffCommand) GetName() string {
+	return "settings-diff"
+}
+
+func (*settingsDiffCommand) GetCompletions(args []string) []string {
+	return (&loadCommand{}).GetCompletions(args)
+}
+
+func printChanges(changes []*settings.Change) {
+	if len(changes) == 0 {
+		fmt.Println("settings match the profile")
+		return
+	}
+
+	for _, c := range changes {
+		name := "(unknown)"
+		if info := settings.Lookup(c.Key); info != nil {
+			name = info.Name
+		}
+		fmt.Printf("%-40s %s\n", c, name)
+	}
+}
+
+func (*settingsDiffCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("settings-diff: profile file not defined")
+	}
+
+	changes, err := a.DiffSettings(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	printChanges(changes)
+	return nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/eiannone/keyboard"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type settingsRestoreCommand struct{}
+
+func (*settingsRestoreCommand) GetName() string {
+	return "settings-restore"
+}
+
+func (*settingsRestoreCommand) GetCompletions(args []string) []string {
+	return (&loadCommand{}).GetCompletions(args)
+}
+
+func (*settingsRestoreCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("settings-restore: profile file not defined")
+	}
+
+	changes, err := a.DiffSettings(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	printChanges(changes)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fmt.Print("Write settings to grbl? [y/N] ")
+
+	ch, _, err := keyboard.GetSingleKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if ch != 'y' && ch != 'Y' {
+		return errors.New("settings-restore: cancelled")
+	}
+
+	return a.RestoreSettings(ctx, args[0])
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type settingsSaveCommand struct{}
+
+func (*settingsSaveCommand) GetName() string {
+	return "settings-save"
+}
+
+func (*settingsSaveCommand) GetCompletions(args []string) []string {
+	return (&loadCommand{}).GetCompletions(args)
+}
+
+func (*settingsSaveCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("settings-save: profile file not defined")
+	}
+
+	return a.SaveSettings(ctx, args[0])
+}
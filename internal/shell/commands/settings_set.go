@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type settingsSetCommand struct{}
+
+func (*settingsSetCommand) GetName() string {
+	return "settings-set"
+}
+
+func (*settingsSetCommand) GetCompletions(args []string) []string {
+	return nil
+}
+
+func (*settingsSetCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("settings-set: no settings defined")
+	}
+
+	type setting struct {
+		key   uint8
+		value float64
+	}
+
+	// parse everything before writing anything
+	s := []setting{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("settings-set: invalid argument: %s", arg)
+		}
+
+		k, err := parseSettingKey(parts[0])
+		if err != nil {
+			return fmt.Errorf("settings-set: %w", err)
+		}
+		v, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return fmt.Errorf("settings-set: invalid value: %s", parts[1])
+		}
+		s = append(s, setting{k, v})
+	}
+
+	for _, st := range s {
+		if err := a.SetSetting(ctx, st.key, st.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package commands
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 
+	"github.com/eiannone/keyboard"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
 )
 
@@ -17,5 +21,133 @@ func (*startCommand) GetCompletions(args []string) []string {
 }
 
 func (*startCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
-	return a.Start(ctx)
+	err := runJob(ctx, a, a.Start)
+
+	var perr *actions.PreflightError
+	if errors.As(err, &perr) {
+		for _, e := range perr.Errors {
+			fmt.Println(e)
+		}
+	}
+	return err
+}
+
+func formatProgress(a *actions.Actions) string {
+	a.Grbl.RLock()
+	rv := a.Grbl.StateName
+	if a.Grbl.WPos != nil {
+		rv += " | W:" + a.Grbl.WPos.String()
+	}
+	rv += fmt.Sprintf(" | F:%.0f", a.Grbl.FeedRate)
+	a.Grbl.RUnlock()
+
+	if p := a.Progress(); p != nil {
+		rv += " | " + p.String()
+	}
+	if tool, ok := a.ToolChangePending(); ok {
+		rv += fmt.Sprintf(" | insert tool T%.0f and press 'c'", tool)
+	}
+	if err := a.LineErrorPending(); err != nil {
+		rv += fmt.Sprintf(" | error at line %d, press 's' to skip or 'a' to abort", err.Line)
+	}
+	return rv
+}
+
+// runJob runs a job-sending function in background, handling realtime
+// command hotkeys and displaying the job progress while it runs.
+func runJob(ctx context.Context, a *actions.Actions, f func(ctx context.Context) error) error {
+	keys, err := keyboard.GetKeys(10)
+	if err != nil {
+		return err
+	}
+	defer keyboard.Close()
+
+	// the progress is displayed until the job is done, even if sending
+	// it was stopped.
+	statusCtx, statusCancel := context.WithCancel(ctx)
+	defer statusCancel()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+
+	status := make(chan struct{}, 1)
+	go func() {
+		for a.Grbl.WaitStatus(statusCtx) == nil {
+			select {
+			case status <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	fmt.Println("Press '!' for feed hold, '~' for cycle start, 'd' for safety door, 'c' to continue after a tool change, 's'/'a' to skip the line or abort after an error, ctrl-x for soft reset and ctrl-c to stop sending the job.")
+	fmt.Println("Overrides: F1/F2/F3 feed -10%/+10%/reset, F5/F6/F7 rapid 25%/50%/100%, F9/F10/F11 spindle -10%/+10%/reset.")
+
+	for {
+		select {
+		case err := <-done:
+			fmt.Printf("\r%s\x1b[K\n", formatProgress(a))
+			return err
+
+		case <-status:
+			fmt.Printf("\r%s\x1b[K", formatProgress(a))
+
+		case ev := <-keys:
+			if ev.Err != nil {
+				return ev.Err
+			}
+
+			if err := func() error {
+				switch ev.Key {
+				case keyboard.KeyCtrlX:
+					return a.Reset(ctx)
+				case keyboard.KeyCtrlC:
+					cancel()
+					return nil
+				case keyboard.KeyF1:
+					return a.FeedOverride(ctx, -10)
+				case keyboard.KeyF2:
+					return a.FeedOverride(ctx, 10)
+				case keyboard.KeyF3:
+					return a.FeedOverrideReset(ctx)
+				case keyboard.KeyF5:
+					return a.RapidOverride(ctx, 25)
+				case keyboard.KeyF6:
+					return a.RapidOverride(ctx, 50)
+				case keyboard.KeyF7:
+					return a.RapidOverride(ctx, 100)
+				case keyboard.KeyF9:
+					return a.SpindleOverride(ctx, -10)
+				case keyboard.KeyF10:
+					return a.SpindleOverride(ctx, 10)
+				case keyboard.KeyF11:
+					return a.SpindleOverrideReset(ctx)
+				}
+
+				switch ev.Rune {
+				case '!':
+					return a.FeedHold(ctx)
+				case '~':
+					return a.CycleStart(ctx)
+				case 'd':
+					return a.SafetyDoor(ctx)
+				case 'c':
+					return a.ToolChangeContinue()
+				case 's':
+					return a.LineErrorContinue(true)
+				case 'a':
+					return a.LineErrorContinue(false)
+				}
+
+				return nil
+			}(); err != nil {
+				log.Printf("error: job: %s", err)
+			}
+		}
+	}
 }
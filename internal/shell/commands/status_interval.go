@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type statusIntervalCommand struct{}
+
+func (*statusIntervalCommand) GetName() string {
+	return "status-interval"
+}
+
+func (*statusIntervalCommand) GetCompletions(args []string) []string {
+	return []string{"100ms", "200ms", "500ms", "1s"}
+}
+
+func (*statusIntervalCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) > 1 {
+		return errors.New("status-interval: too many arguments")
+	}
+
+	if len(args) == 1 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("status-interval: invalid interval: %s", args[0])
+		}
+
+		if err := a.SetStatusInterval(d); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("status interval: %s\n", a.Grbl.StatusInterval())
+	return nil
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type streamingCommand struct{}
+
+func (*streamingCommand) GetName() string {
+	return "streaming"
+}
+
+func (*streamingCommand) GetCompletions(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return []string{"on", "off"}
+}
+
+func (*streamingCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return a.SetStreaming(!a.Grbl.Streaming)
+	}
+
+	switch args[0] {
+	case "on":
+		return a.SetStreaming(true)
+	case "off":
+		return a.SetStreaming(false)
+	}
+
+	return fmt.Errorf("streaming: invalid argument: %s", args[0])
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
+)
+
+type toolChangeConfigCommand struct{}
+
+func (*toolChangeConfigCommand) GetName() string {
+	return "tool-change-config"
+}
+
+func (*toolChangeConfigCommand) GetCompletions(args []string) []string {
+	return []string{"position=", "height=", "probe="}
+}
+
+func parseFloats(v string, n int) ([]float64, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated values: %s", n, v)
+	}
+
+	rv := make([]float64, n)
+	for i, part := range parts {
+		var err error
+		rv[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+func (*toolChangeConfigCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	cfg := a.ToolChangeCfg
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("tool-change-config: invalid argument: %s", arg)
+		}
+
+		switch parts[0] {
+		case "position":
+			if parts[1] == "none" {
+				cfg.Position = nil
+				break
+			}
+			v, err := parseFloats(parts[1], 3)
+			if err != nil {
+				return fmt.Errorf("tool-change-config: invalid position: %w", err)
+			}
+			cfg.Position = &point.Point{X: v[0], Y: v[1], Z: v[2]}
+		case "height":
+			var err error
+			cfg.Height, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return err
+			}
+		case "probe":
+			v, err := parseFloats(parts[1], 2)
+			if err != nil {
+				return fmt.Errorf("tool-change-config: invalid probe position: %w", err)
+			}
+			cfg.ProbeX, cfg.ProbeY = v[0], v[1]
+		default:
+			return fmt.Errorf("tool-change-config: invalid argument: %s", arg)
+		}
+	}
+
+	a.ToolChangeCfg = cfg
+
+	if cfg.Position != nil {
+		fmt.Printf("position: %s (machine)\n", cfg.Position)
+	} else {
+		fmt.Printf("position: current, height: %.3f mm\n", cfg.Height)
+	}
+	fmt.Printf("probe: X=%.3f,Y=%.3f\n", cfg.ProbeX, cfg.ProbeY)
+	return nil
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type wcsCommand struct{}
+
+func (*wcsCommand) GetName() string {
+	return "wcs"
+}
+
+func (*wcsCommand) GetCompletions(args []string) []string {
+	return []string{"G54", "G55", "G56", "G57", "G58", "G59"}
+}
+
+func (*wcsCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) > 1 {
+		return errors.New("wcs: too many arguments")
+	}
+
+	if len(args) == 1 {
+		wcs, err := actions.ParseWCS(args[0])
+		if err != nil {
+			return err
+		}
+		return a.SetWCS(ctx, wcs)
+	}
+
+	o, err := a.Offsets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range o.CoordinateSystems {
+		current := " "
+		if 54+i == o.Current {
+			current = "*"
+		}
+		fmt.Printf("%s G%d: %s\n", current, 54+i, p)
+	}
+	if o.G92 != nil {
+		fmt.Printf("  G92: %s\n", o.G92)
+	}
+	fmt.Printf("  TLO: %.3f\n", o.TLO)
+	return nil
+}
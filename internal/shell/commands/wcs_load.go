@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type wcsLoadCommand struct{}
+
+func (*wcsLoadCommand) GetName() string {
+	return "wcs-load"
+}
+
+func (*wcsLoadCommand) GetCompletions(args []string) []string {
+	return (&loadCommand{}).GetCompletions(args)
+}
+
+func (*wcsLoadCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("wcs-load: offsets file not defined")
+	}
+
+	return a.LoadOffsets(ctx, args[0])
+}
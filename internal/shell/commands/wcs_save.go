@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type wcsSaveCommand struct{}
+
+func (*wcsSaveCommand) GetName() string {
+	return "wcs-save"
+}
+
+func (*wcsSaveCommand) GetCompletions(args []string) []string {
+	return (&loadCommand{}).GetCompletions(args)
+}
+
+func (*wcsSaveCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("wcs-save: offsets file not defined")
+	}
+
+	return a.SaveOffsets(ctx, args[0])
+}
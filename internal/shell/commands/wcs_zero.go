@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+)
+
+type wcsZeroCommand struct{}
+
+func (*wcsZeroCommand) GetName() string {
+	return "wcs-zero"
+}
+
+func (*wcsZeroCommand) GetCompletions(args []string) []string {
+	return []string{"wcs=", "axes="}
+}
+
+func (*wcsZeroCommand) Run(ctx context.Context, a *actions.Actions, args []string) error {
+	wcs := 0
+	axes := "XY"
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("wcs-zero: invalid argument: %s", arg)
+		}
+
+		switch parts[0] {
+		case "wcs":
+			var err error
+			wcs, err = actions.ParseWCS(parts[1])
+			if err != nil {
+				return err
+			}
+		case "axes":
+			axes = parts[1]
+		default:
+			return fmt.Errorf("wcs-zero: invalid argument: %s", arg)
+		}
+	}
+
+	return a.SetZero(ctx, wcs, axes)
+}
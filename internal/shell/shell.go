@@ -12,6 +12,8 @@ import (
 	"github.com/google/shlex"
 	"github.com/peterh/liner"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/response"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/point"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/shell/commands"
 	"golang.org/x/sys/unix"
@@ -25,6 +27,13 @@ func formatAxis(v string, p *point.Point) string {
 	return fmt.Sprintf(" | %s:%s", v, p)
 }
 
+func formatOverrides(ov *response.Overrides) string {
+	if ov == nil || (ov.Feed == 100 && ov.Rapid == 100 && ov.Spindle == 100) {
+		return ""
+	}
+	return " | Ov:" + ov.String()
+}
+
 func formatFile(file string) string {
 	if file != "" {
 		return " | G:" + file
@@ -42,7 +51,7 @@ func Run(a *actions.Actions) error {
 
 	line.SetCompleter(commands.Completer)
 
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, unix.SIGINT, unix.SIGKILL, unix.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -61,7 +70,7 @@ func Run(a *actions.Actions) error {
 	first := true
 
 	for {
-		if err := a.Grbl.SendCommands(ctx, "?"); err != nil {
+		if err := a.Grbl.RefreshStatus(ctx); err != nil {
 			if first {
 				return fmt.Errorf("shell: %w", err)
 			}
@@ -69,7 +78,11 @@ func Run(a *actions.Actions) error {
 			log.Printf("error: shell: %s", err)
 		}
 
-		l, err := line.Prompt("pcb-gcode-sender | " + a.Grbl.StateName + formatAxis("M", a.Grbl.MPos) + formatAxis("W", a.Grbl.WPos) + formatFile(a.CurrentJobFile) + "> ")
+		a.Grbl.RLock()
+		prompt := "pcb-gcode-sender | " + a.Grbl.StateName + formatAxis("M", a.Grbl.MPos) + formatAxis("W", a.Grbl.WPos) + formatOverrides(a.Grbl.Overrides) + formatFile(a.CurrentJobFile) + "> "
+		a.Grbl.RUnlock()
+
+		l, err := line.Prompt(prompt)
 		if err != nil {
 			if err == io.EOF {
 				fmt.Println()
@@ -111,6 +124,11 @@ func Run(a *actions.Actions) error {
 
 		if err := c.Run(ctx, a, parts[1:]); err != nil {
 			log.Printf("error: shell: %s", err)
+
+			var aerr *grbl.AlarmError
+			if errors.As(err, &aerr) {
+				log.Print("shell: use 'recover' to unlock grbl")
+			}
 		}
 
 		select {
@@ -5,10 +5,22 @@ import (
 	"os"
 
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/actions"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/autolevel"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/sim"
+	"github.com/rafaelmartins/pcb-gcode-sender/internal/grbl/usbserial"
 	"github.com/rafaelmartins/pcb-gcode-sender/internal/shell"
 )
 
+func openTransport(device string) (grbl.Transport, error) {
+	// "sim" runs the sender against the built-in grbl simulator, for
+	// offline development.
+	if device == "sim" {
+		return sim.Open(nil)
+	}
+	return usbserial.Open(device)
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		log.Fatal("serial device required")
@@ -20,14 +32,22 @@ func main() {
 		}
 	}
 
-	g, err := grbl.NewGrbl(os.Args[1])
+	t, err := openTransport(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g, err := grbl.NewGrbl(t)
 	if err != nil {
+		t.Close()
 		log.Fatal(err)
 	}
 	defer g.Close()
 
 	a := &actions.Actions{
-		Grbl: g,
+		Grbl:          g,
+		AutoLevelCfg:  autolevel.DefaultConfig(),
+		ToolChangeCfg: actions.DefaultToolChangeConfig(),
 	}
 	if err := shell.Run(a); err != nil {
 		log.Fatal(err)